@@ -19,9 +19,19 @@ func main() {
 	if err != nil {
 		log.Panicf("create ocr error: %+v", err)
 	}
+	defer o.Close()
 
 	if image != "" {
-		results := o.Predict(o.ReadImage(image))
+		img, err := o.ReadImage(image)
+		if err != nil {
+			log.Panicf("read image error: %+v", err)
+		}
+		defer img.Close()
+
+		results, err := o.Predict(img)
+		if err != nil {
+			log.Panicf("predict error: %+v", err)
+		}
 		for _, res := range results {
 			log.Println(res)
 		}
@@ -36,7 +46,17 @@ func main() {
 		names = append(names, pngs...)
 
 		for _, name := range names {
-			results := o.Predict(o.ReadImage(name))
+			img, err := o.ReadImage(name)
+			if err != nil {
+				log.Printf("read image %v error: %+v", name, err)
+				continue
+			}
+			results, err := o.Predict(img)
+			img.Close()
+			if err != nil {
+				log.Printf("predict image %v error: %+v", name, err)
+				continue
+			}
 			log.Printf("======== image: %v =======\n", name)
 			for _, res := range results {
 				log.Println(res)
@@ -5,6 +5,7 @@ go 1.22
 require (
 	github.com/ctessum/go.clipper v0.1.2
 	github.com/paddlepaddle/paddle/paddle/fluid/inference/goapi v0.0.0-20241018162839-3b9f747fe7ea
+	github.com/yalue/onnxruntime_go v1.27.0
 	gocv.io/x/gocv v0.39.0
 	gopkg.in/yaml.v3 v3.0.1
 )
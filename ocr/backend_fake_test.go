@@ -0,0 +1,208 @@
+package ocr
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeScript computes the outputs of a fake model from its input.
+type fakeScript func(shape []int32, data []float32) ([][]float32, [][]int32)
+
+// fakeModels maps the model directories to the scripts of fake backends.
+var fakeModels = map[string]fakeScript{}
+
+func init() {
+	RegisterBackend("fake", func(cfg *PredictorConfig, modelDir string) (InferenceBackend, error) {
+		script, ok := fakeModels[modelDir]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrModelNotFound, modelDir)
+		}
+		return &fakeBackend{script: script}, nil
+	})
+}
+
+// fakeBackend is an InferenceBackend returning the outputs computed by its script.
+type fakeBackend struct {
+	script  fakeScript
+	shape   []int32
+	data    []float32
+	outputs [][]float32
+	shapes  [][]int32
+}
+
+func (b *fakeBackend) SetInput(shape []int32, data []float32) error {
+	b.shape, b.data = shape, data
+	return nil
+}
+
+func (b *fakeBackend) Run() error {
+	b.outputs, b.shapes = b.script(b.shape, b.data)
+	return nil
+}
+
+func (b *fakeBackend) NumOutputs() int {
+	return len(b.outputs)
+}
+
+func (b *fakeBackend) Output(i int) ([]float32, []int32, error) {
+	return b.outputs[i], b.shapes[i], nil
+}
+
+func (b *fakeBackend) Clone() (InferenceBackend, error) {
+	return &fakeBackend{script: b.script}, nil
+}
+
+func (b *fakeBackend) Close() error {
+	return nil
+}
+
+// probMap returns a h x w probability map which is `v` inside `rects` and 0 elsewhere.
+func probMap(h, w int, v float32, rects ...image.Rectangle) []float32 {
+	prob := make([]float32, h*w)
+	for _, r := range rects {
+		r = r.Intersect(image.Rect(0, 0, w, h))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				prob[y*w+x] = v
+			}
+		}
+	}
+	return prob
+}
+
+// probMapScript returns a detector script whose probability map has the size of
+// the input, it is 1 inside `rects` (in input coordinates) and 0 elsewhere.
+func probMapScript(rects ...image.Rectangle) fakeScript {
+	return func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		h, w := int(shape[2]), int(shape[3])
+		return [][]float32{probMap(h, w, 1, rects...)}, [][]int32{{1, 1, int32(h), int32(w)}}
+	}
+}
+
+// inkScript returns a detector script whose probability map is 1 at the bright pixels of the input
+// and 0 elsewhere, so that text drawn in white on black is detected wherever the input comes from.
+func inkScript() fakeScript {
+	return func(shape []int32, data []float32) ([][]float32, [][]int32) {
+		h, w := int(shape[2]), int(shape[3])
+		prob := make([]float32, h*w)
+		for i := range prob {
+			if data[i] > 0 {
+				prob[i] = 1
+			}
+		}
+		return [][]float32{prob}, [][]int32{{1, 1, int32(h), int32(w)}}
+	}
+}
+
+// ctcScript returns a recognizer script which emits the label indexes `paths[i]`
+// for the i-th image of each batch, one label per time step followed by blanks.
+// Each emitted label has probability 0.9.
+func ctcScript(numLabels int, paths ...[]int) fakeScript {
+	return func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		n, steps := int(shape[0]), int(shape[3])/8
+		logits := make([]float32, n*steps*numLabels)
+		for i := 0; i < n; i++ {
+			for t := 0; t < steps; t++ {
+				label := 0
+				if t < len(paths[i%len(paths)]) {
+					label = paths[i%len(paths)][t]
+				}
+				logits[(i*steps+t)*numLabels+label] = 0.9
+			}
+		}
+		return [][]float32{logits}, [][]int32{{int32(n), int32(steps), int32(numLabels)}}
+	}
+}
+
+// clsScript returns a classifier script which predicts `label` for every image.
+func clsScript(label int) fakeScript {
+	return func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		n := int(shape[0])
+		probs := make([]float32, n*2)
+		for i := 0; i < n; i++ {
+			probs[i*2+label] = 0.99
+		}
+		return [][]float32{probs}, [][]int32{{int32(n), 2}}
+	}
+}
+
+// oriScript returns a page orientation classifier script which predicts `label` with `score`.
+func oriScript(label int, score float32) fakeScript {
+	return func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		probs := make([]float32, len(orientationAngles))
+		for i := range probs {
+			probs[i] = (1 - score) / float32(len(probs)-1)
+		}
+		probs[label] = score
+		return [][]float32{probs}, [][]int32{{1, int32(len(probs))}}
+	}
+}
+
+// layoutRegion is a region predicted by layoutScript.
+type layoutRegion struct {
+	label  int
+	score  float32
+	stride int             // index of the stride predicting the region
+	rect   image.Rectangle // box of the region in input coordinates
+}
+
+// layoutScript returns a PicoDet layout script of `numClass` classes and strides 8, 16, 32 and 64,
+// which predicts each region at the cell of its stride containing its center.
+// The distances from the cell center to the edges are rounded to whole strides of at most 7.
+func layoutScript(numClass int, regions ...layoutRegion) fakeScript {
+	strides := []int{8, 16, 32, 64}
+	const regMax = 8
+	return func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		h, w := int(shape[2]), int(shape[3])
+		outputs := make([][]float32, 2*len(strides))
+		shapes := make([][]int32, 2*len(strides))
+		for i, stride := range strides {
+			featW, featH := (w+stride-1)/stride, (h+stride-1)/stride
+			scores := make([]float32, featW*featH*numClass)
+			dists := make([]float32, featW*featH*4*regMax)
+			for _, r := range regions {
+				if r.stride != i {
+					continue
+				}
+				col, row := (r.rect.Min.X+r.rect.Max.X)/2/stride, (r.rect.Min.Y+r.rect.Max.Y)/2/stride
+				idx := row*featW + col
+				scores[idx*numClass+r.label] = r.score
+				ctX, ctY := col*stride+stride/2, row*stride+stride/2
+				for k, d := range []int{ctX - r.rect.Min.X, ctY - r.rect.Min.Y, r.rect.Max.X - ctX, r.rect.Max.Y - ctY} {
+					bin := clamp((d+stride/2)/stride, 0, regMax-1)
+					dists[(idx*4+k)*regMax+bin] = 30
+				}
+			}
+			outputs[i], shapes[i] = scores, []int32{1, int32(featW * featH), int32(numClass)}
+			outputs[len(strides)+i], shapes[len(strides)+i] = dists, []int32{1, int32(featW * featH), 4 * regMax}
+		}
+		return outputs, shapes
+	}
+}
+
+// testLabels are the labels of the char dict written by newTestConfig,
+// with the blank label for ctc and the space label.
+var testLabels = []string{"#", "a", "b", "c", " "}
+
+// newTestConfig returns the config of an engine using fake backends,
+// the models run `det`, `rec` and `cls` scripts respectively.
+func newTestConfig(t *testing.T, det, rec, cls fakeScript) *Config {
+	t.Helper()
+	dict := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(dict, []byte("a\nb\nc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeModels["det"], fakeModels["rec"], fakeModels["cls"] = det, rec, cls
+	cfg := DefaultConfig()
+	cfg.Predictor.Backend = "fake"
+	cfg.Detector.ModelDir = "det"
+	cfg.Recognizer.ModelDir = "rec"
+	cfg.Recognizer.CharDictPath = dict
+	cfg.Classifier.ModelDir = "cls"
+	cfg.Classifier.Enabled = cls != nil
+	return cfg
+}
@@ -0,0 +1,10 @@
+//go:build nopaddle
+
+package ocr
+
+import "errors"
+
+// newPaddleBackend reports that paddle backend is excluded by the `nopaddle` build tag.
+func newPaddleBackend(cfg *PredictorConfig, modelDir string) (InferenceBackend, error) {
+	return nil, errors.New("ocr: paddle backend is not available, built with nopaddle tag")
+}
@@ -0,0 +1,152 @@
+package ocr
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+var (
+	onnxOnce sync.Once
+	onnxErr  error
+)
+
+// initONNXRuntime loads the onnxruntime shared library once per process.
+func initONNXRuntime(lib string) error {
+	onnxOnce.Do(func() {
+		if lib != "" {
+			ort.SetSharedLibraryPath(lib)
+		}
+		onnxErr = ort.InitializeEnvironment()
+	})
+	return onnxErr
+}
+
+// onnxSession is an onnxruntime session shared by cloned backends.
+type onnxSession struct {
+	session     *ort.DynamicAdvancedSession
+	inputNames  []string
+	outputNames []string
+	refs        atomic.Int32
+}
+
+// onnxBackend runs the inference on CPU with onnxruntime.
+type onnxBackend struct {
+	session *onnxSession
+	input   *ort.Tensor[float32]
+	outputs []ort.Value
+}
+
+// newONNXBackend loads the model `model.onnx` in `modelDir`, which is usually
+// converted from the paddle model by paddle2onnx.
+func newONNXBackend(cfg *PredictorConfig, modelDir string) (InferenceBackend, error) {
+	modelPath := path.Join(modelDir, "model.onnx")
+	if !isPathExist(modelPath) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, modelDir)
+	}
+	if err := initONNXRuntime(cfg.ONNXRuntimeLib); err != nil {
+		return nil, fmt.Errorf("init onnxruntime: %w", err)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("read onnx model %s: %w", modelPath, err)
+	}
+	inputNames := []string{inputs[0].Name}
+	outputNames := make([]string, len(outputs))
+	for i, output := range outputs {
+		outputNames[i] = output.Name
+	}
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer options.Destroy()
+	if cfg.NumCPUThreads > 0 {
+		if err := options.SetIntraOpNumThreads(cfg.NumCPUThreads); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, outputNames, options)
+	if err != nil {
+		return nil, fmt.Errorf("create onnx session %s: %w", modelPath, err)
+	}
+	s := &onnxSession{session: session, inputNames: inputNames, outputNames: outputNames}
+	s.refs.Add(1)
+	return &onnxBackend{session: s}, nil
+}
+
+func (b *onnxBackend) SetInput(shape []int32, data []float32) error {
+	dims := make([]int64, len(shape))
+	for i, v := range shape {
+		dims[i] = int64(v)
+	}
+	input, err := ort.NewTensor(ort.NewShape(dims...), data)
+	if err != nil {
+		return err
+	}
+	if b.input != nil {
+		b.input.Destroy()
+	}
+	b.input = input
+	return nil
+}
+
+func (b *onnxBackend) Run() error {
+	b.destroyOutputs()
+	b.outputs = make([]ort.Value, len(b.session.outputNames))
+	return b.session.session.Run([]ort.Value{b.input}, b.outputs)
+}
+
+func (b *onnxBackend) NumOutputs() int {
+	return len(b.session.outputNames)
+}
+
+func (b *onnxBackend) Output(i int) ([]float32, []int32, error) {
+	output, ok := b.outputs[i].(*ort.Tensor[float32])
+	if !ok {
+		return nil, nil, fmt.Errorf("ocr: onnx output %s is not a float32 tensor", b.session.outputNames[i])
+	}
+	data := make([]float32, len(output.GetData()))
+	copy(data, output.GetData())
+	shape := make([]int32, len(output.GetShape()))
+	for i, v := range output.GetShape() {
+		shape[i] = int32(v)
+	}
+	return data, shape, nil
+}
+
+// Clone creates a backend sharing the session with b,
+// onnxruntime sessions can run concurrently with different tensors.
+func (b *onnxBackend) Clone() (InferenceBackend, error) {
+	b.session.refs.Add(1)
+	return &onnxBackend{session: b.session}, nil
+}
+
+// Close destroys the tensors of the backend,
+// and the session once all the backends sharing it are closed.
+func (b *onnxBackend) Close() error {
+	if b.input != nil {
+		b.input.Destroy()
+		b.input = nil
+	}
+	b.destroyOutputs()
+	if b.session.refs.Add(-1) == 0 {
+		return b.session.session.Destroy()
+	}
+	return nil
+}
+
+func (b *onnxBackend) destroyOutputs() {
+	for _, output := range b.outputs {
+		if output != nil {
+			output.Destroy()
+		}
+	}
+	b.outputs = nil
+}
@@ -0,0 +1,95 @@
+//go:build !nopaddle
+
+package ocr
+
+import (
+	"fmt"
+	"path"
+
+	pd "github.com/paddlepaddle/paddle/paddle/fluid/inference/goapi"
+)
+
+// paddleBackend runs the inference with paddle inference library.
+type paddleBackend struct {
+	predictor *pd.Predictor
+	input     *pd.Tensor
+	outputs   []*pd.Tensor
+}
+
+// newPaddleBackend loads the paddle model `inference.model` and `inference.params` in `modelDir`.
+func newPaddleBackend(cfg *PredictorConfig, modelDir string) (InferenceBackend, error) {
+	if !isPathExist(path.Join(modelDir, "inference.model")) ||
+		!isPathExist(path.Join(modelDir, "inference.params")) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, modelDir)
+	}
+
+	config := pd.NewConfig()
+	config.DisableGlogInfo()
+	config.SetModel(path.Join(modelDir, "inference.model"), path.Join(modelDir, "inference.params"))
+
+	if cfg.UseGPU {
+		config.EnableUseGpu(cfg.GPUMem, cfg.GPUID)
+	} else {
+		// config.DisableGpu()
+		config.SetCpuMathLibraryNumThreads(cfg.NumCPUThreads)
+		if cfg.UseMKLDNN {
+			config.EnableMKLDNN()
+		}
+	}
+
+	// false for zero copy tensor
+	// config.SwitchUseFeedFetchOps(false)
+	// config.SwitchSpecifyInputNames(true)
+	config.SwitchIrOptim(cfg.UseIROptim)
+	config.EnableMemoryOptim(true)
+	return newPaddleBackendFromPredictor(pd.NewPredictor(config)), nil
+}
+
+func newPaddleBackendFromPredictor(predictor *pd.Predictor) *paddleBackend {
+	names := predictor.GetOutputNames()
+	outputs := make([]*pd.Tensor, len(names))
+	for i, name := range names {
+		outputs[i] = predictor.GetOutputHandle(name)
+	}
+	return &paddleBackend{
+		predictor: predictor,
+		input:     predictor.GetInputHandle(predictor.GetInputNames()[0]),
+		outputs:   outputs,
+	}
+}
+
+func (b *paddleBackend) SetInput(shape []int32, data []float32) error {
+	b.input.Reshape(shape)
+	b.input.CopyFromCpu(data)
+	return nil
+}
+
+func (b *paddleBackend) Run() error {
+	b.predictor.Run()
+	return nil
+}
+
+func (b *paddleBackend) NumOutputs() int {
+	return len(b.outputs)
+}
+
+func (b *paddleBackend) Output(i int) ([]float32, []int32, error) {
+	shape := b.outputs[i].Shape()
+	data := make([]float32, accumulate(shape))
+	b.outputs[i].CopyToCpu(data)
+	return data, shape, nil
+}
+
+func (b *paddleBackend) Clone() (InferenceBackend, error) {
+	return newPaddleBackendFromPredictor(b.predictor.Clone()), nil
+}
+
+// Close releases the intermediate tensors and the memory pool of the predictor,
+// and drops the paddle handles. The Go API of paddle inference has no method to destroy a predictor,
+// so the predictor itself and its loaded model are only freed by their finalizers once the GC runs.
+func (b *paddleBackend) Close() error {
+	b.predictor.ClearIntermediateTensor()
+	b.predictor.TryShrinkMemory()
+	b.predictor, b.input, b.outputs = nil, nil, nil
+	return nil
+}
@@ -1,6 +1,7 @@
 package ocr
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"log"
@@ -12,7 +13,7 @@ import (
 
 // classifier is the text classifier.
 type classifier struct {
-	*Predictor
+	pool     *predictorPool
 	batchNum int
 	thresh   float32
 	shape    []int
@@ -34,15 +35,15 @@ func newClassifier(cfg *Config) (*classifier, error) {
 	if !ccfg.Enabled {
 		return nil, nil
 	}
-	model, err := NewPredictor(&cfg.Predictor, ccfg.ModelDir)
+	pool, err := newPredictorPool(&cfg.Predictor, ccfg.ModelDir)
 	if err != nil {
 		return nil, err
 	}
 	return &classifier{
-		Predictor: model,
-		thresh:    ccfg.Thresh,
-		batchNum:  ccfg.BatchNum,
-		shape:     ccfg.ImageShape,
+		pool:     pool,
+		thresh:   ccfg.Thresh,
+		batchNum: ccfg.BatchNum,
+		shape:    ccfg.ImageShape,
 
 		mean:    []float32{0.5, 0.5, 0.5},
 		scale:   []float32{1 / 0.5, 1 / 0.5, 1 / 0.5},
@@ -50,11 +51,20 @@ func newClassifier(cfg *Config) (*classifier, error) {
 	}, nil
 }
 
-func (p *classifier) run(imgs []gocv.Mat) ([]gocv.Mat, []Direction) {
+func (p *classifier) run(ctx context.Context, imgs []gocv.Mat) ([]gocv.Mat, []Direction, error) {
 	t := time.Now()
 	directions := make([]Direction, len(imgs))
 	c, h, w := p.shape[0], p.shape[1], p.shape[2]
+
+	model, err := p.pool.get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer p.pool.put(model)
 	for i := 0; i < len(imgs); i += p.batchNum {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 		j := min(i+p.batchNum, len(imgs))
 
 		normImgs := []gocv.Mat{}
@@ -69,13 +79,10 @@ func (p *classifier) run(imgs []gocv.Mat) ([]gocv.Mat, []Direction) {
 			normImgs = append(normImgs, resizeImg)
 		}
 
-		p.input.Reshape([]int32{int32(j - i), int32(c), int32(h), int32(w)})
-		p.input.CopyFromCpu(permuteBatch(normImgs))
-		p.predictor.Run()
-
-		shape := p.output.Shape()
-		predicts := make([]float32, accumulate(shape))
-		p.output.CopyToCpu(predicts)
+		predicts, shape, err := model.Run([]int32{int32(j - i), int32(c), int32(h), int32(w)}, permuteBatch(normImgs))
+		if err != nil {
+			return nil, nil, err
+		}
 
 		for m := 0; m < int(shape[0]); m++ {
 			l := m * int(shape[1])
@@ -86,13 +93,18 @@ func (p *classifier) run(imgs []gocv.Mat) ([]gocv.Mat, []Direction) {
 	}
 
 	for i, dir := range directions {
-		if dir.Label%2 == 1 && dir.Score > p.thresh {
+		if p.rotated(dir) {
 			gocv.Rotate(imgs[i], &imgs[i], gocv.Rotate180Clockwise)
 		}
 	}
 
 	log.Printf("classifier: box num: %d, elapsed: %dms\n", len(directions), time.Since(t).Milliseconds())
-	return imgs, directions
+	return imgs, directions, nil
+}
+
+// rotated reports whether the text image of direction `dir` is rotated 180 degrees.
+func (p *classifier) rotated(dir Direction) bool {
+	return dir.Label%2 == 1 && dir.Score > p.thresh
 }
 
 func (p *classifier) resize(img gocv.Mat, resizeShape []int) gocv.Mat {
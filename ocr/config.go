@@ -1,13 +1,25 @@
 package ocr
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"slices"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// PredictorConfig is the configuration for paddle predictor.
+// PredictorConfig is the configuration for model predictor.
 type PredictorConfig struct {
+	// Backend is the inference backend, BackendPaddle (default) or BackendONNX.
+	Backend string `yaml:"backend"`
+	// ONNXRuntimeLib is the path of onnxruntime shared library for BackendONNX,
+	// defaults to onnxruntime.so in the library search path.
+	ONNXRuntimeLib string `yaml:"onnxruntime_lib"`
+
 	UseGPU        bool   `yaml:"use_gpu"`
 	UseMKLDNN     bool   `yaml:"use_mkldnn"`
 	UseTensorrt   bool   `yaml:"use_tensorrt"`
@@ -15,51 +27,356 @@ type PredictorConfig struct {
 	NumCPUThreads int    `yaml:"num_cpu_threads"`
 	GPUID         int32  `yaml:"gpu_id"`
 	GPUMem        uint64 `yaml:"gpu_mem"`
+
+	// PoolSize is the number of predictors created for each model,
+	// i.e. the number of requests that can run the model concurrently.
+	PoolSize int `yaml:"pool_size"`
+	// PoolTimeout is the maximum time to wait for an idle predictor.
+	// Zero or negative means waiting forever.
+	PoolTimeout time.Duration `yaml:"pool_timeout"`
+}
+
+// DetectorConfig is the configuration for text detector.
+type DetectorConfig struct {
+	ModelDir     string  `yaml:"model_dir"`
+	LimitType    string  `yaml:"limit_type"`
+	LimitSideLen int     `yaml:"limit_side_len"`
+	Thresh       float32 `yaml:"thresh"`
+	BoxThresh    float64 `yaml:"box_thresh"`
+	UnclipRatio  float64 `yaml:"unclip_ratio"`
+	ScoreMode    string  `yaml:"score_mode"`
+	UseDilation  bool    `yaml:"use_dilation"`
+	// BoxType is BoxTypeQuad (default) or BoxTypePoly, which keeps the polygons of curved text
+	// in Result.Polygon and rectifies them piecewise for recognition.
+	BoxType string `yaml:"box_type"`
+	// TileSize splits images larger than it into overlapping tiles detected at native resolution,
+	// so that small text on large images is not lost by resizing. Zero disables tiling.
+	TileSize int `yaml:"tile_size"`
+	// TileOverlap is the overlap of adjacent tiles in pixels,
+	// it should be larger than the text height.
+	TileOverlap int `yaml:"tile_overlap"`
+	// Scales are the limit side lengths to detect the text at, e.g. [640, 960, 1600],
+	// replacing LimitSideLen. The boxes of different scales are fused by FusionIoU and FusionContain.
+	// Tiling takes precedence over scales for images larger than TileSize.
+	Scales []int `yaml:"scales"`
+	// FusionIoU is the minimum IoU of boxes of different scales to merge them.
+	FusionIoU float64 `yaml:"fusion_iou"`
+	// FusionContain is the minimum fraction of a box covered by a box of another scale to merge them,
+	// e.g. the fragments of a large headline detected at a high scale.
+	FusionContain float64 `yaml:"fusion_contain"`
+}
+
+// RecognizerConfig is the configuration for text recognizer.
+type RecognizerConfig struct {
+	// Language is the name of the recognizer model, selecting it with WithLanguages.
+	Language     string `yaml:"language"`
+	ModelDir     string `yaml:"model_dir"`
+	BatchNum     int    `yaml:"batch_num"`
+	ImageShape   []int  `yaml:"image_shape"`
+	CharDictPath string `yaml:"char_dict_path"`
+	// UseSpaceChar adds the space label after the labels of the char dict,
+	// it must match the recognizer model.
+	UseSpaceChar bool `yaml:"use_space_char"`
+	// MaxTextLength is the maximum number of chars of a text line, zero (default) disables the limit.
+	// Longer lines are truncated, or split if SplitLongText, and flagged by Result.LongText.
+	MaxTextLength int  `yaml:"max_text_length"`
+	SplitLongText bool `yaml:"split_long_text"`
+	// VerticalText is the recognition mode of vertical text columns, i.e. boxes or polygons at least 1.5 times taller than wide:
+	// VerticalRotate (default) rotates the columns 90 degrees counterclockwise for models trained on vertical text,
+	// VerticalStack cuts the characters of the columns and stacks them upright for models of horizontal text.
+	VerticalText string `yaml:"vertical_text"`
+	// AllowedChars restricts the recognized text to these chars, empty allows all chars.
+	AllowedChars string `yaml:"allowed_chars"`
+	// DeniedChars are never recognized.
+	DeniedChars string `yaml:"denied_chars"`
+	// ReturnChars returns the characters of the text with their scores and boxes in Result.Chars.
+	ReturnChars bool `yaml:"return_chars"`
+
+	// Decoder is the CTC decoder, DecoderGreedy (default) or DecoderBeam.
+	Decoder   string `yaml:"decoder"`
+	BeamWidth int    `yaml:"beam_width"`
+	// LexiconPath is the file of words (one per line) scoring the beam search hypotheses.
+	LexiconPath string `yaml:"lexicon_path"`
+	// LMWeight is the weight of language model score in beam search.
+	LMWeight float64 `yaml:"lm_weight"`
+	// TopK is the number of alternative readings returned in Result.Alternatives,
+	// found by beam search of at least TopK beams whatever the Decoder.
+	TopK int `yaml:"top_k"`
+	// LanguageModel scores the beam search hypotheses, it takes precedence over LexiconPath.
+	LanguageModel LanguageModel `yaml:"-"`
+}
+
+// LanguageConfig is the configuration for the recognizer model of an extra language.
+// The settings missing here are shared with RecognizerConfig.
+type LanguageConfig struct {
+	ModelDir     string `yaml:"model_dir"`
+	CharDictPath string `yaml:"char_dict_path"`
+	// ImageShape defaults to the image shape of RecognizerConfig.
+	ImageShape []int `yaml:"image_shape"`
+	// LexiconPath is the lexicon of the language for beam search.
+	LexiconPath string `yaml:"lexicon_path"`
+}
+
+// ClassifierConfig is the configuration for text direction classifier.
+type ClassifierConfig struct {
+	Enabled    bool    `yaml:"enabled"`
+	ModelDir   string  `yaml:"model_dir"`
+	Thresh     float32 `yaml:"thresh"`
+	BatchNum   int     `yaml:"batch_num"`
+	ImageShape []int   `yaml:"image_shape"`
+}
+
+// OrientationConfig is the configuration for the page orientation classifier,
+// e.g. PP-LCNet_x1_0_doc_ori of PaddleOCR, predicting the rotation of 0, 90, 180 or 270 degrees.
+type OrientationConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	ModelDir string `yaml:"model_dir"`
+	// Thresh is the minimum score of the predicted orientation to rotate the image.
+	Thresh     float32 `yaml:"thresh"`
+	ImageShape []int   `yaml:"image_shape"`
+}
+
+// DeskewConfig is the configuration for deskewing the pages.
+// The skew is the median angle of the detected text lines, the image is rotated by it
+// and the text is detected again in the rotated image.
+type DeskewConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinAngle is the minimum skew in degrees to rotate the image.
+	MinAngle float64 `yaml:"min_angle"`
+	// MaxAngle is the maximum skew in degrees to correct, pages rotated further are left to `orientation`.
+	MaxAngle float64 `yaml:"max_angle"`
+}
+
+// LayoutConfig is the configuration for the layout detector, e.g. the PicoDet layout models
+// of PP-Structure, finding the titles, tables, figures and lists of the pages.
+type LayoutConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	ModelDir string `yaml:"model_dir"`
+	// Labels are the region labels of the model classes, e.g. of layout_publaynet_dict.txt.
+	Labels []string `yaml:"labels"`
+	// Thresh is the minimum score of the regions.
+	Thresh float32 `yaml:"thresh"`
+	// NMSThresh is the maximum IoU of the regions of a label, the lower scored ones are dropped.
+	NMSThresh float64 `yaml:"nms_thresh"`
+	// FPNStrides are the strides of the model outputs.
+	FPNStrides []int `yaml:"fpn_strides"`
+	ImageShape []int `yaml:"image_shape"`
+}
+
+// ReadingOrderConfig is the configuration for sorting the results in reading order
+// and grouping them into columns, paragraphs and lines.
+type ReadingOrderConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// ColumnGap is the minimum gap between columns relative to the median height of the text lines.
+	ColumnGap float64 `yaml:"column_gap"`
+	// ParagraphGap is the minimum extra spacing between paragraphs relative to the median height
+	// of the text lines, over the median spacing of the lines of the column.
+	ParagraphGap float64 `yaml:"paragraph_gap"`
+}
+
+// LanguageIDConfig is the configuration for identifying the language of each text line.
+type LanguageIDConfig struct {
+	// Enabled recognizes each text line with the recognizer of its language,
+	// unless the languages are selected by WithLanguages.
+	Enabled bool `yaml:"enabled"`
+	// ModelDir is the language classifier model. If it is empty, the text lines are
+	// recognized in all languages, keeping the best scored reading.
+	ModelDir string `yaml:"model_dir"`
+	// Labels are the class names of the classifier outputs, e.g. of PULC language_classification.
+	Labels []string `yaml:"labels"`
+	// LabelLanguages maps the labels to the names of the languages, i.e. `recognizer.language`
+	// or the keys of Languages. Labels missing from the map are language names themselves.
+	LabelLanguages map[string]string `yaml:"label_languages"`
+	// Thresh is the minimum score of the identified language,
+	// text lines of lower scores or unknown languages use the default recognizer.
+	Thresh     float32 `yaml:"thresh"`
+	BatchNum   int     `yaml:"batch_num"`
+	ImageShape []int   `yaml:"image_shape"`
 }
 
 // Config is the configuration for OCR engine.
 // Refer: https://github.com/PaddlePaddle/PaddleOCR/blob/release/2.7/deploy/cpp_infer/src/args.cpp
 type Config struct {
-	Predictor PredictorConfig `yaml:"predictor"`
-
-	Detector struct {
-		ModelDir     string  `yaml:"model_dir"`
-		LimitType    string  `yaml:"limit_type"`
-		LimitSideLen int     `yaml:"limit_side_len"`
-		Thresh       float32 `yaml:"thresh"`
-		BoxThresh    float64 `yaml:"box_thresh"`
-		UnclipRatio  float64 `yaml:"unclip_ratio"`
-		ScoreMode    string  `yaml:"score_mode"`
-		UseDilation  bool    `yaml:"use_dilation"`
-	} `yaml:"detector"`
-
-	Recognizer struct {
-		ModelDir      string `yaml:"model_dir"`
-		BatchNum      int    `yaml:"batch_num"`
-		ImageShape    []int  `yaml:"image_shape"`
-		CharDictPath  string `yaml:"char_dict_path"`
-		MaxTextLength int    `yaml:"max_text_length"`
-	} `yaml:"recognizer"`
-
-	Classifier struct {
-		Enabled    bool    `yaml:"enabled"`
-		ModelDir   string  `yaml:"model_dir"`
-		Thresh     float32 `yaml:"thresh"`
-		BatchNum   int     `yaml:"batch_num"`
-		ImageShape []int   `yaml:"image_shape"`
-	} `yaml:"classifier"`
+	Predictor  PredictorConfig  `yaml:"predictor"`
+	Detector   DetectorConfig   `yaml:"detector"`
+	Recognizer RecognizerConfig `yaml:"recognizer"`
+	Classifier ClassifierConfig `yaml:"classifier"`
+	// Orientation rotates pages scanned sideways or upside down upright before detection.
+	Orientation OrientationConfig `yaml:"orientation"`
+	// Deskew straightens slightly rotated scans before recognition.
+	Deskew DeskewConfig `yaml:"deskew"`
+	// ReadingOrder sorts the results of multi-column pages in reading order.
+	ReadingOrder ReadingOrderConfig `yaml:"reading_order"`
+	// Layout labels the regions of the pages and the results inside them.
+	Layout LayoutConfig `yaml:"layout"`
+	// Languages are the recognizer models of extra languages by name,
+	// sharing the detector and the classifier with Recognizer.
+	Languages  map[string]LanguageConfig `yaml:"languages"`
+	LanguageID LanguageIDConfig          `yaml:"language_id"`
+}
+
+// DefaultConfig returns the default configuration, which matches the defaults
+// of PaddleOCR C++ deployment. Model directories and char dict are left empty.
+func DefaultConfig() *Config {
+	return &Config{
+		Predictor: PredictorConfig{
+			UseIROptim:    true,
+			NumCPUThreads: 10,
+			GPUMem:        4000,
+			PoolSize:      1,
+		},
+		Detector: DetectorConfig{
+			LimitType:     "max",
+			LimitSideLen:  960,
+			Thresh:        0.3,
+			BoxThresh:     0.6,
+			UnclipRatio:   1.5,
+			ScoreMode:     "slow",
+			BoxType:       BoxTypeQuad,
+			TileOverlap:   128,
+			FusionIoU:     0.5,
+			FusionContain: 0.8,
+		},
+		Recognizer: RecognizerConfig{
+			Language:     "ch",
+			BatchNum:     6,
+			ImageShape:   []int{3, 48, 320},
+			UseSpaceChar: true,
+			VerticalText: VerticalRotate,
+			Decoder:      DecoderGreedy,
+			BeamWidth:    10,
+			LMWeight:     1,
+		},
+		Classifier: ClassifierConfig{
+			Thresh:     0.9,
+			BatchNum:   1,
+			ImageShape: []int{3, 48, 192},
+		},
+		Orientation: OrientationConfig{
+			Thresh:     0.8,
+			ImageShape: []int{3, 224, 224},
+		},
+		Deskew: DeskewConfig{
+			MinAngle: 0.5,
+			MaxAngle: 15,
+		},
+		ReadingOrder: ReadingOrderConfig{
+			ColumnGap:    1,
+			ParagraphGap: 0.4,
+		},
+		Layout: LayoutConfig{
+			Labels:     []string{"text", "title", "list", "table", "figure"},
+			Thresh:     0.4,
+			NMSThresh:  0.5,
+			FPNStrides: []int{8, 16, 32, 64},
+			ImageShape: []int{3, 800, 608},
+		},
+		LanguageID: LanguageIDConfig{
+			LabelLanguages: map[string]string{"latin": "en"},
+			Thresh:         0.5,
+			BatchNum:       6,
+			ImageShape:     []int{3, 80, 160},
+		},
+	}
 }
 
 // ReadConfig reads the OCR engine configuration from .yaml file.
+// Fields missing in the file keep the values of DefaultConfig.
 func ReadConfig(name string) (*Config, error) {
 	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, name)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("read config %s: %w", name, err)
 	}
 
-	cfg := &Config{}
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", name, err)
 	}
 	return cfg, nil
 }
+
+// clone returns a deep copy of the config, except LanguageModel which is shared.
+func (c *Config) clone() *Config {
+	cc := *c
+	cc.Detector.Scales = slices.Clone(c.Detector.Scales)
+	cc.Recognizer.ImageShape = slices.Clone(c.Recognizer.ImageShape)
+	cc.Classifier.ImageShape = slices.Clone(c.Classifier.ImageShape)
+	cc.Orientation.ImageShape = slices.Clone(c.Orientation.ImageShape)
+	cc.Layout.Labels = slices.Clone(c.Layout.Labels)
+	cc.Layout.FPNStrides = slices.Clone(c.Layout.FPNStrides)
+	cc.Layout.ImageShape = slices.Clone(c.Layout.ImageShape)
+	cc.LanguageID.Labels = slices.Clone(c.LanguageID.Labels)
+	cc.LanguageID.LabelLanguages = maps.Clone(c.LanguageID.LabelLanguages)
+	cc.LanguageID.ImageShape = slices.Clone(c.LanguageID.ImageShape)
+	if c.Languages != nil {
+		cc.Languages = make(map[string]LanguageConfig, len(c.Languages))
+		for lang, lcfg := range c.Languages {
+			lcfg.ImageShape = slices.Clone(lcfg.ImageShape)
+			cc.Languages[lang] = lcfg
+		}
+	}
+	return &cc
+}
+
+// validate checks the batch sizes and the image shapes of the enabled models.
+func (c *Config) validate() error {
+	if err := checkBatchNum("recognizer", c.Recognizer.BatchNum); err != nil {
+		return err
+	}
+	if err := checkImageShape("recognizer", c.Recognizer.ImageShape); err != nil {
+		return err
+	}
+	for lang, lcfg := range c.Languages {
+		if len(lcfg.ImageShape) == 0 {
+			continue
+		}
+		if err := checkImageShape("languages."+lang, lcfg.ImageShape); err != nil {
+			return err
+		}
+	}
+	if c.Classifier.Enabled {
+		if err := checkBatchNum("classifier", c.Classifier.BatchNum); err != nil {
+			return err
+		}
+		if err := checkImageShape("classifier", c.Classifier.ImageShape); err != nil {
+			return err
+		}
+	}
+	if c.LanguageID.Enabled && c.LanguageID.ModelDir != "" {
+		if err := checkBatchNum("language_id", c.LanguageID.BatchNum); err != nil {
+			return err
+		}
+		if err := checkImageShape("language_id", c.LanguageID.ImageShape); err != nil {
+			return err
+		}
+	}
+	if c.Orientation.Enabled {
+		if err := checkImageShape("orientation", c.Orientation.ImageShape); err != nil {
+			return err
+		}
+	}
+	if c.Layout.Enabled {
+		if err := checkImageShape("layout", c.Layout.ImageShape); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkBatchNum(name string, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: %s.batch_num %d is not positive", ErrInvalidConfig, name, n)
+	}
+	return nil
+}
+
+// checkImageShape checks that the image shape is [channels, height, width] of positive sizes.
+func checkImageShape(name string, shape []int) error {
+	if len(shape) != 3 || slices.Min(shape) <= 0 {
+		return fmt.Errorf("%w: %s.image_shape %v is not [channels, height, width]", ErrInvalidConfig, name, shape)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package ocr
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	cfg, err := ReadConfig("../config/conf.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Detector.LimitSideLen != 960 || cfg.Detector.BoxThresh != 0.6 {
+		t.Errorf("got detector config %+v", cfg.Detector)
+	}
+	if !slices.Equal(cfg.Recognizer.ImageShape, []int{3, 48, 320}) {
+		t.Errorf("got recognizer image shape %v, want [3 48 320]", cfg.Recognizer.ImageShape)
+	}
+	if cfg.Recognizer.MaxTextLength != 0 {
+		t.Errorf("got max text length %d, want no limit", cfg.Recognizer.MaxTextLength)
+	}
+	if !cfg.Classifier.Enabled || cfg.Predictor.PoolSize != 1 {
+		t.Errorf("got config %+v", cfg)
+	}
+
+	if _, err := ReadConfig("missing.yaml"); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrConfigNotFound)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(c *Config)
+	}{
+		{"recognizer batch", func(c *Config) { c.Recognizer.BatchNum = 0 }},
+		{"recognizer shape", func(c *Config) { c.Recognizer.ImageShape = nil }},
+		{"recognizer short shape", func(c *Config) { c.Recognizer.ImageShape = []int{3, 48} }},
+		{"language shape", func(c *Config) { c.Languages = map[string]LanguageConfig{"en": {ImageShape: []int{3, 0, 320}}} }},
+		{"classifier batch", func(c *Config) { c.Classifier.Enabled, c.Classifier.BatchNum = true, -1 }},
+		{"classifier shape", func(c *Config) { c.Classifier.Enabled, c.Classifier.ImageShape = true, []int{48, 192} }},
+		{"language id batch", func(c *Config) { c.LanguageID.Enabled, c.LanguageID.ModelDir, c.LanguageID.BatchNum = true, "lid", 0 }},
+		{"orientation shape", func(c *Config) { c.Orientation.Enabled, c.Orientation.ImageShape = true, []int{} }},
+		{"layout shape", func(c *Config) { c.Layout.Enabled, c.Layout.ImageShape = true, []int{3, 800, -1} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.edit(cfg)
+			if _, err := NewWithConfig(cfg); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidConfig)
+			}
+		})
+	}
+
+	// the settings of disabled models are not checked.
+	cfg := DefaultConfig()
+	cfg.Classifier.BatchNum, cfg.Layout.ImageShape = 0, nil
+	if err := cfg.validate(); err != nil {
+		t.Errorf("got error %v for disabled models", err)
+	}
+}
+
+func TestConfigClone(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"en": {ImageShape: []int{3, 48, 320}}}
+	c := cfg.clone()
+	c.Recognizer.ImageShape[2] = 640
+	c.Languages["en"].ImageShape[2] = 640
+	c.LanguageID.LabelLanguages["latin"] = "fr"
+	if cfg.Recognizer.ImageShape[2] != 320 || cfg.Languages["en"].ImageShape[2] != 320 || cfg.LanguageID.LabelLanguages["latin"] != "en" {
+		t.Errorf("got config %+v modified by its clone", cfg)
+	}
+}
@@ -0,0 +1,346 @@
+package ocr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CTC decoders supported by RecognizerConfig.Decoder.
+const (
+	DecoderGreedy = "greedy"
+	DecoderBeam   = "beam"
+)
+
+// beamPruneProb is the minimum probability of a label to extend the beams at a time step.
+const beamPruneProb = 1e-3
+
+// LanguageModel scores the hypotheses of CTC beam search decoding.
+type LanguageModel interface {
+	// Score returns the log score of appending `next` to the text `prefix`,
+	// 0 means neutral and negative values penalize the hypothesis.
+	Score(prefix, next string) float64
+}
+
+// Lexicon is a LanguageModel preferring the words in the lexicon.
+// Words are separated by spaces, text without spaces is a single word.
+type Lexicon struct {
+	prefixes map[string]struct{}
+}
+
+// NewLexicon creates a lexicon of the given words.
+func NewLexicon(words []string) *Lexicon {
+	l := &Lexicon{prefixes: make(map[string]struct{})}
+	for _, word := range words {
+		for i := range word {
+			l.prefixes[word[:i]] = struct{}{}
+		}
+		l.prefixes[word] = struct{}{}
+	}
+	return l
+}
+
+// ReadLexicon reads the lexicon from the file containing one word per line.
+func ReadLexicon(name string) (*Lexicon, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lexicon %s: %w", name, err)
+	}
+	return NewLexicon(strings.Fields(string(data))), nil
+}
+
+// Score returns -1 for every char that makes the current word not a prefix of any lexicon word.
+func (l *Lexicon) Score(prefix, next string) float64 {
+	if next == " " {
+		return 0
+	}
+	text := prefix + next
+	if _, ok := l.prefixes[text[strings.LastIndexByte(text, ' ')+1:]]; ok {
+		return 0
+	}
+	return -1
+}
+
+// hypothesis is a decoded label sequence of a text line.
+type hypothesis struct {
+	labels []int
+	probs  []float32 // probability of each label
+	spans  [][2]int  // first and last time step of each label
+	score  float64   // log score used to rank the hypotheses
+}
+
+// confidence returns the mean probability of the labels.
+func (h hypothesis) confidence() float32 {
+	if len(h.probs) == 0 {
+		return 0
+	}
+	var sum float32
+	for _, prob := range h.probs {
+		sum += prob
+	}
+	return sum / float32(len(h.probs))
+}
+
+// truncate returns the hypothesis of the first `n` labels.
+func (h hypothesis) truncate(n int) hypothesis {
+	if len(h.labels) <= n {
+		return h
+	}
+	h.labels, h.probs, h.spans = h.labels[:n], h.probs[:n], h.spans[:n]
+	return h
+}
+
+// rescore returns the hypothesis decoded from masked probabilities with the probability of each label
+// taken from the unmasked `probs` at the best time step of its span, so that text forced into
+// the allowed chars is not reported as confident.
+func (h hypothesis) rescore(probs []float32, numLabels int) hypothesis {
+	if len(h.spans) != len(h.labels) {
+		return h
+	}
+	scores := make([]float32, len(h.labels))
+	for i, label := range h.labels {
+		for n := h.spans[i][0]; n <= h.spans[i][1]; n++ {
+			scores[i] = max(scores[i], probs[n*numLabels+label])
+		}
+	}
+	h.probs = scores
+	return h
+}
+
+// greedyDecode takes the most probable label at each time step,
+// then removes the repeated labels and blanks.
+// `probs` is the `steps` x `numLabels` output of a single text line.
+func greedyDecode(probs []float32, steps, numLabels int) hypothesis {
+	var (
+		h         hypothesis
+		lastIndex int
+	)
+	for n := 0; n < steps; n++ {
+		argmaxIdx, maxValue := argmax(probs[n*numLabels : (n+1)*numLabels])
+		if argmaxIdx > 0 && (!(n > 0 && argmaxIdx == lastIndex)) {
+			h.labels = append(h.labels, argmaxIdx)
+			h.probs = append(h.probs, maxValue)
+			h.spans = append(h.spans, [2]int{n, n})
+		} else if argmaxIdx > 0 {
+			h.spans[len(h.spans)-1][1] = n
+		}
+		h.score += math.Log(float64(maxValue))
+		lastIndex = argmaxIdx
+	}
+	return h
+}
+
+// maskProbs returns a copy of the probabilities with the labels not in `mask` zeroed and each time step
+// renormalized, so that the decoders pick the most probable allowed labels.
+// The renormalized probabilities only choose the decoding path, see hypothesis.rescore.
+// `probs` is the `steps` x `numLabels` output of a single text line.
+func maskProbs(probs []float32, steps, numLabels int, mask []bool) []float32 {
+	masked := make([]float32, len(probs))
+	copy(masked, probs)
+	for n := 0; n < steps; n++ {
+		step := masked[n*numLabels : (n+1)*numLabels]
+		var sum float32
+		for c := range step {
+			if c < len(mask) && !mask[c] {
+				step[c] = 0
+			}
+			sum += step[c]
+		}
+		if sum == 0 {
+			continue
+		}
+		for c := range step {
+			step[c] /= sum
+		}
+	}
+	return masked
+}
+
+// beam is a prefix of CTC prefix beam search.
+type beam struct {
+	labels []int
+	probs  []float32
+	spans  [][2]int // label spans of the most probable extension merged into the beam
+	pspans float64  // log probability of the extension giving the spans
+	pb     float64  // log probability of the paths ending in blank
+	pnb    float64  // log probability of the paths ending in non-blank
+	lm     float64  // weighted language model score
+}
+
+func (b *beam) score() float64 {
+	return logAdd(b.pb, b.pnb) + b.lm
+}
+
+// align sets the label spans of the beam if the extension of log probability `p` is the most probable one.
+func (b *beam) align(spans [][2]int, p float64) {
+	if p > b.pspans || len(b.spans) != len(b.labels) {
+		b.spans, b.pspans = spans, p
+	}
+}
+
+// spansWith returns a copy of `spans` with the last one ending at time step `n`,
+// or a new span at `n` appended if `extend` is true.
+func spansWith(spans [][2]int, n int, extend bool) [][2]int {
+	if extend {
+		return append(append([][2]int(nil), spans...), [2]int{n, n})
+	}
+	spans = append([][2]int(nil), spans...)
+	spans[len(spans)-1][1] = n
+	return spans
+}
+
+// beamDecoder decodes the CTC output with prefix beam search,
+// optionally scoring the prefixes with a language model.
+type beamDecoder struct {
+	width    int
+	labels   []string
+	lm       LanguageModel
+	lmWeight float64
+}
+
+// decode returns at most `width` hypotheses sorted by score in descending order.
+// `probs` is the `steps` x `numLabels` output of a single text line.
+func (d *beamDecoder) decode(probs []float32, steps, numLabels int) []hypothesis {
+	beams := map[string]*beam{"": {pb: 0, pnb: math.Inf(-1)}}
+	for n := 0; n < steps; n++ {
+		step := probs[n*numLabels : (n+1)*numLabels]
+		best, _ := argmax(step)
+
+		next := make(map[string]*beam, len(beams))
+		for key, b := range beams {
+			last := -1
+			if len(b.labels) > 0 {
+				last = b.labels[len(b.labels)-1]
+			}
+			for c, prob := range step {
+				if prob < beamPruneProb && c != best {
+					continue
+				}
+				p := math.Log(float64(prob))
+				if c == 0 {
+					nb := d.extend(next, key, b, -1, 0)
+					nb.pb = logAdd(nb.pb, logAdd(b.pb, b.pnb)+p)
+					nb.align(b.spans, logAdd(b.pb, b.pnb)+p)
+					continue
+				}
+
+				nb := d.extend(next, key, b, c, prob)
+				if c == last {
+					// repeated labels are merged unless separated by a blank.
+					nb.pnb = logAdd(nb.pnb, b.pb+p)
+					nb.align(spansWith(b.spans, n, true), b.pb+p)
+					same := d.extend(next, key, b, -1, 0)
+					same.pnb = logAdd(same.pnb, b.pnb+p)
+					same.probs[len(same.probs)-1] = max(same.probs[len(same.probs)-1], prob)
+					same.align(spansWith(b.spans, n, false), b.pnb+p)
+				} else {
+					nb.pnb = logAdd(nb.pnb, logAdd(b.pb, b.pnb)+p)
+					nb.align(spansWith(b.spans, n, true), logAdd(b.pb, b.pnb)+p)
+				}
+			}
+		}
+		beams = d.prune(next)
+	}
+
+	hyps := make([]hypothesis, 0, len(beams))
+	for _, key := range d.sortedKeys(beams) {
+		b := beams[key]
+		// skip the impossible prefixes, e.g. repeated labels without a blank in between.
+		if len(hyps) > 0 && math.IsInf(b.score(), -1) {
+			break
+		}
+		hyps = append(hyps, hypothesis{labels: b.labels, probs: b.probs, spans: b.spans, score: b.score()})
+	}
+	return hyps
+}
+
+// extend returns the beam of `b` extended by label `c` in `next`, creating it if needed.
+// A negative `c` returns the beam of the same prefix as `b`.
+func (d *beamDecoder) extend(next map[string]*beam, key string, b *beam, c int, prob float32) *beam {
+	if c >= 0 {
+		key += string(binary.AppendUvarint(nil, uint64(c)))
+	}
+	if nb, ok := next[key]; ok {
+		if c >= 0 {
+			nb.probs[len(nb.probs)-1] = max(nb.probs[len(nb.probs)-1], prob)
+		}
+		return nb
+	}
+
+	nb := &beam{
+		labels: b.labels,
+		probs:  append([]float32(nil), b.probs...),
+		pspans: math.Inf(-1),
+		pb:     math.Inf(-1),
+		pnb:    math.Inf(-1),
+		lm:     b.lm,
+	}
+	if c >= 0 {
+		nb.labels = append(append([]int(nil), b.labels...), c)
+		nb.probs = append(nb.probs, prob)
+		if d.lm != nil {
+			nb.lm += d.lmWeight * d.lm.Score(joinLabels(d.labels, b.labels), d.labels[c])
+		}
+	}
+	next[key] = nb
+	return nb
+}
+
+// prune keeps the best `width` beams.
+func (d *beamDecoder) prune(beams map[string]*beam) map[string]*beam {
+	if len(beams) <= d.width {
+		return beams
+	}
+	pruned := make(map[string]*beam, d.width)
+	for _, key := range d.sortedKeys(beams)[:d.width] {
+		pruned[key] = beams[key]
+	}
+	return pruned
+}
+
+// sortedKeys returns the keys of the beams sorted by score in descending order.
+func (d *beamDecoder) sortedKeys(beams map[string]*beam) []string {
+	keys := make([]string, 0, len(beams))
+	for key := range beams {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		si, sj := beams[keys[i]].score(), beams[keys[j]].score()
+		if si != sj {
+			return si > sj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// joinLabels returns the text of the label indexes.
+func joinLabels(dict []string, labels []int) string {
+	var sb strings.Builder
+	for _, label := range labels {
+		sb.WriteString(dict[label])
+	}
+	return sb.String()
+}
+
+// logAdd returns log(exp(a) + exp(b)).
+func logAdd(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
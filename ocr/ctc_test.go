@@ -0,0 +1,108 @@
+package ocr
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// oneHot returns the `len(path)` x `numLabels` output emitting `path` with probability `prob`,
+// the remaining probability of each step is given to the blank.
+func oneHot(numLabels int, prob float32, path ...int) []float32 {
+	probs := make([]float32, len(path)*numLabels)
+	for n, label := range path {
+		probs[n*numLabels] = 1 - prob
+		probs[n*numLabels+label] += prob
+	}
+	return probs
+}
+
+func TestGreedyDecode(t *testing.T) {
+	h := greedyDecode(oneHot(3, 0.8, 1, 1, 0, 1, 2, 0), 6, 3)
+	if want := []int{1, 1, 2}; !slices.Equal(h.labels, want) {
+		t.Errorf("got labels %v, want %v", h.labels, want)
+	}
+	if want := [][2]int{{0, 1}, {3, 3}, {4, 4}}; !slices.Equal(h.spans, want) {
+		t.Errorf("got spans %v, want %v", h.spans, want)
+	}
+	if got := h.confidence(); math.Abs(float64(got-0.8)) > 1e-6 {
+		t.Errorf("got confidence %v, want 0.8", got)
+	}
+}
+
+func TestBeamDecode(t *testing.T) {
+	// the blank is the most probable label at each step,
+	// but "a" is the most probable text summing all its alignments.
+	probs := []float32{0.6, 0.4, 0.6, 0.4}
+	if h := greedyDecode(probs, 2, 2); len(h.labels) != 0 {
+		t.Fatalf("got greedy labels %v, want none", h.labels)
+	}
+
+	d := &beamDecoder{width: 5, labels: []string{"#", "a"}}
+	hyps := d.decode(probs, 2, 2)
+	if len(hyps) != 2 {
+		t.Fatalf("got %d hypotheses, want 2", len(hyps))
+	}
+	if !slices.Equal(hyps[0].labels, []int{1}) || len(hyps[1].labels) != 0 {
+		t.Errorf("got hypotheses %v, %v, want [1], []", hyps[0].labels, hyps[1].labels)
+	}
+	if got := math.Exp(hyps[0].score); math.Abs(got-0.64) > 1e-6 {
+		t.Errorf("got probability %v, want 0.64", got)
+	}
+	if got := hyps[0].confidence(); math.Abs(float64(got-0.4)) > 1e-6 {
+		t.Errorf("got confidence %v, want 0.4", got)
+	}
+}
+
+func TestBeamDecodeRepeated(t *testing.T) {
+	probs := oneHot(3, 0.9, 1, 1, 0, 1, 2, 2)
+	d := &beamDecoder{width: 10, labels: []string{"#", "a", "b"}}
+	hyps := d.decode(probs, 6, 3)
+	if want := []int{1, 1, 2}; !slices.Equal(hyps[0].labels, want) {
+		t.Errorf("got labels %v, want %v", hyps[0].labels, want)
+	}
+	if want := [][2]int{{0, 1}, {3, 3}, {4, 5}}; !slices.Equal(hyps[0].spans, want) {
+		t.Errorf("got spans %v, want %v", hyps[0].spans, want)
+	}
+	for i := 1; i < len(hyps); i++ {
+		if hyps[i].score > hyps[i-1].score {
+			t.Errorf("hypotheses are not sorted by score: %v > %v", hyps[i].score, hyps[i-1].score)
+		}
+	}
+}
+
+func TestBeamDecodeLexicon(t *testing.T) {
+	probs := []float32{0.05, 0.5, 0.45}
+	labels := []string{"#", "a", "b"}
+
+	d := &beamDecoder{width: 5, labels: labels}
+	if got := d.decode(probs, 1, 3)[0].labels; !slices.Equal(got, []int{1}) {
+		t.Errorf("got labels %v without lexicon, want [1]", got)
+	}
+
+	d.lm, d.lmWeight = NewLexicon([]string{"b", "bb"}), 1
+	if got := d.decode(probs, 1, 3)[0].labels; !slices.Equal(got, []int{2}) {
+		t.Errorf("got labels %v with lexicon, want [2]", got)
+	}
+}
+
+func TestLexiconScore(t *testing.T) {
+	l := NewLexicon([]string{"中文", "ab"})
+	tests := []struct {
+		prefix, next string
+		want         float64
+	}{
+		{"", "中", 0},
+		{"中", "文", 0},
+		{"中文", "字", -1},
+		{"a", "b", 0},
+		{"ab", " ", 0},
+		{"ab ", "a", 0},
+		{"ab a", "c", -1},
+	}
+	for _, tt := range tests {
+		if got := l.Score(tt.prefix, tt.next); got != tt.want {
+			t.Errorf("Score(%q, %q) = %v, want %v", tt.prefix, tt.next, got, tt.want)
+		}
+	}
+}
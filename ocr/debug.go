@@ -0,0 +1,81 @@
+package ocr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// DetectionMaps are the outputs of the text detector scaled to the size of the source image,
+// filled by the WithDetectionMaps option to tell whether missed text is due to the model or the thresholds.
+// The maps of tiles and scales are merged by taking the maximum of each pixel.
+type DetectionMaps struct {
+	// Prob is the DB probability map, 8-bit single channel where 255 means probability 1.
+	Prob gocv.Mat
+	// Bitmap is the probability map thresholded by `detector.thresh` (and dilated if `detector.use_dilation`),
+	// 8-bit single channel where 255 means text.
+	Bitmap gocv.Mat
+}
+
+// ProbPNG returns the probability map encoded as PNG.
+func (m *DetectionMaps) ProbPNG() ([]byte, error) {
+	return encodePNG(m.Prob)
+}
+
+// BitmapPNG returns the thresholded bitmap encoded as PNG.
+func (m *DetectionMaps) BitmapPNG() ([]byte, error) {
+	return encodePNG(m.Bitmap)
+}
+
+// Close releases the maps.
+func (m *DetectionMaps) Close() error {
+	return errors.Join(m.Prob.Close(), m.Bitmap.Close())
+}
+
+// reset replaces the maps by blank maps of size `w` x `h`, releasing the previous ones if any.
+func (m *DetectionMaps) reset(w, h int) {
+	if !m.Prob.Closed() {
+		m.Prob.Close()
+	}
+	if !m.Bitmap.Closed() {
+		m.Bitmap.Close()
+	}
+	m.Prob = gocv.Zeros(h, w, gocv.MatTypeCV8UC1)
+	m.Bitmap = gocv.Zeros(h, w, gocv.MatTypeCV8UC1)
+}
+
+// region returns the maps of the region of the source image, sharing the data of `m`.
+func (m *DetectionMaps) region(rect image.Rectangle) *DetectionMaps {
+	return &DetectionMaps{Prob: m.Prob.Region(rect), Bitmap: m.Bitmap.Region(rect)}
+}
+
+// add merges the probability map and bitmap predicted for the source image into the maps.
+func (m *DetectionMaps) add(pred, bitmap gocv.Mat) {
+	size := image.Pt(m.Prob.Cols(), m.Prob.Rows())
+
+	prob := gocv.NewMat()
+	defer prob.Close()
+	gocv.Resize(pred, &prob, size, 0, 0, gocv.InterpolationLinear)
+	prob.ConvertToWithParams(&prob, gocv.MatTypeCV8UC1, 255, 0)
+	gocv.Max(m.Prob, prob, &m.Prob)
+
+	mask := gocv.NewMat()
+	defer mask.Close()
+	gocv.Resize(bitmap, &mask, size, 0, 0, gocv.InterpolationNearestNeighbor)
+	gocv.Max(m.Bitmap, mask, &m.Bitmap)
+}
+
+// encodePNG encodes the image as PNG.
+func encodePNG(img gocv.Mat) ([]byte, error) {
+	if img.Closed() || img.Empty() {
+		return nil, ErrEmptyInput
+	}
+	buf, err := gocv.IMEncode(gocv.PNGFileExt, img)
+	if err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	defer buf.Close()
+	return append([]byte(nil), buf.GetBytes()...), nil
+}
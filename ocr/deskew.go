@@ -0,0 +1,122 @@
+package ocr
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// deskewer estimates the skew of scanned pages from the detected text lines.
+type deskewer struct {
+	minAngle float64
+	maxAngle float64
+}
+
+// newDeskewer creates the deskewer, it returns nil if deskewing is disabled.
+func newDeskewer(cfg *Config) *deskewer {
+	if !cfg.Deskew.Enabled {
+		return nil
+	}
+	return &deskewer{minAngle: cfg.Deskew.MinAngle, maxAngle: cfg.Deskew.MaxAngle}
+}
+
+// angle returns the clockwise skew in degrees of the page, the median angle of the text line boxes
+// weighted by their length. It returns 0 if the skew is below the min angle or above the max angle.
+func (d *deskewer) angle(boxes [][][]int) float64 {
+	type line struct{ angle, length float64 }
+	var (
+		lines []line
+		total float64
+	)
+	for _, box := range boxes {
+		// the top edge of the box clockwise from the top left point.
+		dx, dy := float64(box[1][0]-box[0][0]), float64(box[1][1]-box[0][1])
+		length := math.Hypot(dx, dy)
+		height := math.Hypot(float64(box[3][0]-box[0][0]), float64(box[3][1]-box[0][1]))
+		// only the boxes of text lines tell the direction of the text.
+		if length < 2*height {
+			continue
+		}
+		lines = append(lines, line{math.Atan2(dy, dx) * 180 / math.Pi, length})
+		total += length
+	}
+	if len(lines) == 0 {
+		return 0
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].angle < lines[j].angle })
+	var angle, sum float64
+	for _, l := range lines {
+		angle = l.angle
+		if sum += l.length; sum >= total/2 {
+			break
+		}
+	}
+	if math.Abs(angle) < d.minAngle || math.Abs(angle) > d.maxAngle {
+		return 0
+	}
+	return angle
+}
+
+// rotateImage rotates the image counterclockwise by `angle` degrees around its center,
+// expanding the image to keep its corners. It returns the rotated image and the affine
+// transform mapping the rotated image back to the source image.
+func rotateImage(img gocv.Mat, angle float64) (gocv.Mat, [2][3]float64) {
+	w, h := float64(img.Cols()), float64(img.Rows())
+	rad := angle * math.Pi / 180
+	cos, sin := math.Abs(math.Cos(rad)), math.Abs(math.Sin(rad))
+	rw, rh := int(math.Ceil(w*cos+h*sin)), int(math.Ceil(w*sin+h*cos))
+
+	m := gocv.GetRotationMatrix2D(image.Pt(int(w/2), int(h/2)), angle, 1)
+	defer m.Close()
+	m.SetDoubleAt(0, 2, m.GetDoubleAt(0, 2)+float64(rw-int(w))/2)
+	m.SetDoubleAt(1, 2, m.GetDoubleAt(1, 2)+float64(rh-int(h))/2)
+
+	rotated := gocv.NewMat()
+	gocv.WarpAffineWithParams(img, &rotated, m, image.Pt(rw, rh), gocv.InterpolationLinear, gocv.BorderReplicate, color.RGBA{0, 0, 0, 0})
+
+	inv := gocv.NewMat()
+	defer inv.Close()
+	gocv.InvertAffineTransform(m, &inv)
+	var t [2][3]float64
+	for i := range t {
+		for j := range t[i] {
+			t[i][j] = inv.GetDoubleAt(i, j)
+		}
+	}
+	return rotated, t
+}
+
+// remapResults maps the boxes of the results by the affine transform `t`,
+// clipping them into the image of size `w` x `h`.
+func remapResults(results []Result, t [2][3]float64, w, h int) {
+	remap := remapper(t, w, h)
+	for i := range results {
+		remap(results[i].BBox)
+		remap(results[i].Polygon)
+		for _, c := range results[i].Chars {
+			remap(c.BBox)
+		}
+	}
+}
+
+// remapper returns a function mapping the points in place by the affine transform `t`,
+// clipping them into the image of size `w` x `h`.
+func remapper(t [2][3]float64, w, h int) func(points [][]int) {
+	// the boxes of split text lines share their points with the boxes of their chars.
+	seen := make(map[*int]bool)
+	return func(points [][]int) {
+		for _, pt := range points {
+			if seen[&pt[0]] {
+				continue
+			}
+			seen[&pt[0]] = true
+			x, y := float64(pt[0]), float64(pt[1])
+			pt[0] = clamp(int(math.Round(t[0][0]*x+t[0][1]*y+t[0][2])), 0, w-1)
+			pt[1] = clamp(int(math.Round(t[1][0]*x+t[1][1]*y+t[1][2])), 0, h-1)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// skewedBox returns the box of size `w` x `h` centered at (`cx`, `cy`) rotated clockwise by `angle` degrees.
+func skewedBox(cx, cy, w, h, angle float64) [][]int {
+	rad := angle * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	box := make([][]int, 0, 4)
+	for _, p := range [][2]float64{{-w / 2, -h / 2}, {w / 2, -h / 2}, {w / 2, h / 2}, {-w / 2, h / 2}} {
+		box = append(box, []int{int(math.Round(cx + p[0]*cos - p[1]*sin)), int(math.Round(cy + p[0]*sin + p[1]*cos))})
+	}
+	return box
+}
+
+func TestDeskewerAngle(t *testing.T) {
+	d := &deskewer{minAngle: 0.5, maxAngle: 15}
+	tests := []struct {
+		name  string
+		boxes [][][]int
+		want  float64
+	}{
+		{"lines", [][][]int{skewedBox(200, 100, 300, 20, 3), skewedBox(200, 150, 300, 20, 3), skewedBox(200, 200, 100, 20, -5)}, 3},
+		{"counterclockwise", [][][]int{skewedBox(200, 100, 300, 20, -4)}, -4},
+		{"ignores blocks", [][][]int{skewedBox(200, 100, 300, 20, 3), skewedBox(200, 200, 100, 80, 12), skewedBox(300, 200, 100, 80, 12)}, 3},
+		{"below min angle", [][][]int{skewedBox(200, 100, 300, 20, 0)}, 0},
+		{"above max angle", [][][]int{skewedBox(200, 100, 300, 20, 30)}, 0},
+		{"no lines", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := d.angle(tt.boxes); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("%s: got angle %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPredictPageDeskew(t *testing.T) {
+	const skew = 5
+	line := skewedBox(200, 100, 300, 20, skew)
+	img := gocv.Zeros(200, 400, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	pv := gocv.NewPointsVectorFromPoints([][]image.Point{toPoints(line)})
+	gocv.FillPoly(&img, pv, color.RGBA{255, 255, 255, 0})
+	pv.Close()
+
+	for _, enabled := range []bool{false, true} {
+		cfg := newTestConfig(t, inkScript(), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+		cfg.Deskew.Enabled = enabled
+		o, err := NewWithConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.Close()
+
+		page, err := o.PredictPage(context.Background(), img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Results) != 1 || page.Results[0].Text != "ab" {
+			t.Fatalf("deskew %v: got %+v, want a single result of text %q", enabled, page.Results, "ab")
+		}
+		if want := map[bool]float64{false: 0, true: skew}[enabled]; math.Abs(page.Skew-want) > 1 {
+			t.Errorf("deskew %v: got skew %v, want %v", enabled, page.Skew, want)
+		}
+
+		// the box is mapped back to the skewed line of the image.
+		box := page.Results[0].BBox
+		if got := math.Atan2(float64(box[1][1]-box[0][1]), float64(box[1][0]-box[0][0])) * 180 / math.Pi; math.Abs(got-skew) > 1 {
+			t.Errorf("deskew %v: got box %v of angle %v, want %v", enabled, box, got, skew)
+		}
+		if got, want := boxBounds(box), boxBounds(line); !want.In(got.Inset(-2)) || !got.In(want.Inset(-20)) {
+			t.Errorf("deskew %v: got box %v, want a box covering %v", enabled, got, want)
+		}
+	}
+}
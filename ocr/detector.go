@@ -1,6 +1,7 @@
 package ocr
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"log"
@@ -15,7 +16,7 @@ import (
 
 // detector is the text detector.
 type detector struct {
-	*Predictor
+	pool         *predictorPool
 	limitType    string
 	limitSideLen int
 
@@ -25,7 +26,13 @@ type detector struct {
 	unClipRatio   float64
 	minSize       float32
 	useDilation   bool
+	boxType       string
 	scoreMode     string
+	tileSize      int
+	tileOverlap   int
+	scales        []int
+	fusionIoU     float64
+	fusionContain float64
 
 	mean    []float32
 	scale   []float32
@@ -35,12 +42,12 @@ type detector struct {
 // newDetector creates a new text detector.
 func newDetector(cfg *Config) (*detector, error) {
 	dcfg := cfg.Detector
-	model, err := NewPredictor(&cfg.Predictor, dcfg.ModelDir)
+	pool, err := newPredictorPool(&cfg.Predictor, dcfg.ModelDir)
 	if err != nil {
 		return nil, err
 	}
 	return &detector{
-		Predictor:    model,
+		pool:         pool,
 		limitType:    dcfg.LimitType,
 		limitSideLen: dcfg.LimitSideLen,
 
@@ -49,6 +56,12 @@ func newDetector(cfg *Config) (*detector, error) {
 		unClipRatio:   dcfg.UnclipRatio,
 		scoreMode:     dcfg.ScoreMode,
 		useDilation:   dcfg.UseDilation,
+		boxType:       dcfg.BoxType,
+		tileSize:      dcfg.TileSize,
+		tileOverlap:   dcfg.TileOverlap,
+		scales:        dcfg.Scales,
+		fusionIoU:     dcfg.FusionIoU,
+		fusionContain: dcfg.FusionContain,
 		minSize:       3,
 		maxCandidates: 1000,
 
@@ -58,47 +71,84 @@ func newDetector(cfg *Config) (*detector, error) {
 	}, nil
 }
 
-func (d *detector) Run(img gocv.Mat) [][][]int {
+// Run returns the boxes of the text in the image,
+// filling `maps` with the outputs of the model if not nil.
+func (d *detector) Run(ctx context.Context, img gocv.Mat, maps *DetectionMaps) ([][][]int, error) {
 	t := time.Now()
+	model, err := d.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.pool.put(model)
+
+	if maps != nil {
+		maps.reset(img.Cols(), img.Rows())
+	}
+	var boxes [][][]int
+	if d.tileSize > 0 && max(img.Rows(), img.Cols()) > d.tileSize {
+		boxes, err = d.runTiles(ctx, model, img, maps)
+	} else if len(d.scales) > 0 {
+		boxes, err = d.runScales(ctx, model, img, maps)
+	} else {
+		boxes, err = d.predict(model, img, d.ratio(img, d.limitSideLen), maps)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("detector: box num: %d, elapsed: %dms\n", len(boxes), time.Since(t).Milliseconds())
+	return boxes, nil
+}
+
+// predict runs the model on the image resized by `ratio` and returns the boxes in the image,
+// merging the outputs of the model into `maps` if not nil.
+func (d *detector) predict(model *Predictor, img gocv.Mat, ratio float64, maps *DetectionMaps) ([][][]int, error) {
 	h, w := img.Rows(), img.Cols()
-	resizeImg, ratioH, ratioW := d.Resize(img)
+	resizeImg, ratioH, ratioW := d.resizeBy(img, ratio)
 	defer resizeImg.Close()
 
 	normalize(resizeImg, d.mean, d.scale, d.isScale)
 
-	d.input.Reshape([]int32{1, 3, int32(resizeImg.Rows()), int32(resizeImg.Cols())})
-	d.input.CopyFromCpu(permute(resizeImg))
-	d.predictor.Run()
-
-	boxes := d.postProcess(h, w, ratioH, ratioW)
-
-	log.Printf("detector: box num: %d, elapsed: %dms\n", len(boxes), time.Since(t).Milliseconds())
-	return boxes
+	predicts, shape, err := model.Run([]int32{1, 3, int32(resizeImg.Rows()), int32(resizeImg.Cols())}, permute(resizeImg))
+	if err != nil {
+		return nil, err
+	}
+	return d.postProcess(predicts, shape, h, w, ratioH, ratioW, maps), nil
 }
 
 func (d *detector) Resize(img gocv.Mat) (gocv.Mat, float64, float64) {
+	return d.resizeBy(img, d.ratio(img, d.limitSideLen))
+}
+
+// ratio returns the resize ratio of the image limited by the limit type and `limitSideLen`.
+func (d *detector) ratio(img gocv.Mat, limitSideLen int) float64 {
 	w, h := img.Cols(), img.Rows()
 	ratio := 1.0
 	if d.limitType == "min" {
 		minWh := min(h, w)
-		if minWh < d.limitSideLen {
+		if minWh < limitSideLen {
 			if h < w {
-				ratio = float64(d.limitSideLen) / float64(h)
+				ratio = float64(limitSideLen) / float64(h)
 			} else {
-				ratio = float64(d.limitSideLen) / float64(w)
+				ratio = float64(limitSideLen) / float64(w)
 			}
 		}
 	} else {
 		maxWh := max(h, w)
-		if maxWh > d.limitSideLen {
+		if maxWh > limitSideLen {
 			if h > w {
-				ratio = float64(d.limitSideLen) / float64(h)
+				ratio = float64(limitSideLen) / float64(h)
 			} else {
-				ratio = float64(d.limitSideLen) / float64(w)
+				ratio = float64(limitSideLen) / float64(w)
 			}
 		}
 	}
+	return ratio
+}
 
+// resizeBy resizes the image by `ratio`, rounding its sides to multiples of 32.
+func (d *detector) resizeBy(img gocv.Mat, ratio float64) (gocv.Mat, float64, float64) {
+	w, h := img.Cols(), img.Rows()
 	resizeH := int(float64(h) * ratio)
 	resizeW := int(float64(w) * ratio)
 
@@ -302,13 +352,11 @@ func filterTagDetRes(boxes [][][]int, oriH, oriW int, ratioH, ratioW float64) []
 	return points
 }
 
-func (d *detector) postProcess(oriH, oriW int, ratioH, ratioW float64) [][][]int {
-	shape := d.output.Shape()
+// postProcess returns the boxes of the text in the source image of size `oriW` x `oriH` from the model output,
+// merging the probability map and bitmap into `maps` if not nil.
+func (d *detector) postProcess(predicts []float32, shape []int32, oriH, oriW int, ratioH, ratioW float64, maps *DetectionMaps) [][][]int {
 	h, w := int(shape[2]), int(shape[3])
 
-	predicts := make([]float32, accumulate(shape))
-	d.output.CopyToCpu(predicts)
-
 	pred := gocv.NewMatWithSize(h, w, gocv.MatTypeCV32F)
 	defer pred.Close()
 
@@ -331,7 +379,14 @@ func (d *detector) postProcess(oriH, oriW int, ratioH, ratioW float64) [][][]int
 		kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Point{2, 2})
 		gocv.Dilate(bitmap, &bitmap, kernel)
 	}
+	if maps != nil {
+		maps.add(pred, bitmap)
+	}
 
+	if d.boxType == BoxTypePoly {
+		polys := d.polygonsFromBitmap(pred, bitmap)
+		return filterTagDetResOnlyClip(polys, oriH, oriW, ratioH, ratioW)
+	}
 	boxes := d.boxesFromBitmap(pred, bitmap)
 	return filterTagDetRes(boxes, oriH, oriW, ratioH, ratioW)
 }
@@ -0,0 +1,275 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func newTestDetector(t *testing.T) *detector {
+	t.Helper()
+	d, err := newDetector(newTestConfig(t, probMapScript(), nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.pool.close() })
+	return d
+}
+
+// boxBounds returns the bounding rectangle of the box or polygon, including its max point.
+func boxBounds(box [][]int) image.Rectangle {
+	xs, ys := make([]int, len(box)), make([]int, len(box))
+	for i, pt := range box {
+		xs[i], ys[i] = pt[0], pt[1]
+	}
+	return image.Rect(slices.Min(xs), slices.Min(ys), slices.Max(xs), slices.Max(ys))
+}
+
+func TestDetectorPostProcess(t *testing.T) {
+	const h, w = 64, 96
+	tests := []struct {
+		name  string
+		prob  float32
+		rects []image.Rectangle
+		want  int
+	}{
+		{"single", 1, []image.Rectangle{image.Rect(10, 20, 50, 30)}, 1},
+		{"multiple", 1, []image.Rectangle{image.Rect(10, 5, 50, 15), image.Rect(10, 40, 80, 55)}, 2},
+		{"below box thresh", 0.5, []image.Rectangle{image.Rect(10, 20, 50, 30)}, 0},
+		{"below thresh", 0.2, []image.Rectangle{image.Rect(10, 20, 50, 30)}, 0},
+		{"too small", 1, []image.Rectangle{image.Rect(10, 20, 12, 22)}, 0},
+		{"empty", 1, nil, 0},
+	}
+
+	d := newTestDetector(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := d.postProcess(probMap(h, w, tt.prob, tt.rects...), []int32{1, 1, h, w}, h, w, 1, 1, nil)
+			if len(boxes) != tt.want {
+				t.Fatalf("got %d boxes, want %d: %v", len(boxes), tt.want, boxes)
+			}
+
+			boxes = sortBoxes(boxes)
+			for i, box := range boxes {
+				got, rect := boxBounds(box), tt.rects[i]
+				// the box is unclipped, so it covers the text region and extends beyond it.
+				if got.Min.X >= rect.Min.X || got.Min.Y >= rect.Min.Y || got.Max.X < rect.Max.X || got.Max.Y < rect.Max.Y {
+					t.Errorf("box %d: got %v, want a box covering %v", i, got, rect)
+				}
+				if !got.In(image.Rect(0, 0, w, h)) {
+					t.Errorf("box %d: got %v, want a box inside the image", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectorPostProcessScale(t *testing.T) {
+	d := newTestDetector(t)
+	// the map is predicted on the image resized by 0.5.
+	boxes := d.postProcess(probMap(32, 64, 1, image.Rect(8, 8, 40, 16)), []int32{1, 1, 32, 64}, 64, 128, 0.5, 0.5, nil)
+	if len(boxes) != 1 {
+		t.Fatalf("got %d boxes, want 1", len(boxes))
+	}
+	got := boxBounds(boxes[0])
+	if got.Min.X >= 16 || got.Min.Y >= 16 || got.Max.X < 80 || got.Max.Y < 32 {
+		t.Errorf("got %v, want a box covering %v", got, image.Rect(16, 16, 80, 32))
+	}
+}
+
+func TestBoxesFromBitmap(t *testing.T) {
+	const h, w = 48, 64
+	rect := image.Rect(8, 16, 56, 32)
+	prob := probMap(h, w, 0.8, rect)
+
+	pred := gocv.NewMatWithSize(h, w, gocv.MatTypeCV32F)
+	defer pred.Close()
+	bitmap := gocv.Zeros(h, w, gocv.MatTypeCV8UC1)
+	defer bitmap.Close()
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			pred.SetFloatAt(i, j, prob[i*w+j])
+			if prob[i*w+j] > 0 {
+				bitmap.SetUCharAt(i, j, 255)
+			}
+		}
+	}
+
+	for _, mode := range []string{"fast", "slow"} {
+		t.Run(mode, func(t *testing.T) {
+			d := newTestDetector(t)
+			d.scoreMode = mode
+			boxes := d.boxesFromBitmap(pred, bitmap)
+			if len(boxes) != 1 {
+				t.Fatalf("got %d boxes, want 1", len(boxes))
+			}
+			if got := boxBounds(orderPointsClockwise(boxes[0])); !rect.In(got.Inset(-1)) {
+				t.Errorf("got %v, want a box covering %v", got, rect)
+			}
+
+			d.boxThresh = 0.9
+			if boxes := d.boxesFromBitmap(pred, bitmap); len(boxes) != 0 {
+				t.Errorf("got %d boxes with box thresh 0.9, want 0", len(boxes))
+			}
+		})
+	}
+}
+
+func TestDetectorPostProcessPoly(t *testing.T) {
+	const h, w = 64, 96
+	rect := image.Rect(10, 20, 50, 30)
+	d := newTestDetector(t)
+	d.boxType = BoxTypePoly
+
+	// the map is predicted on the image resized by 0.5.
+	polys := d.postProcess(probMap(h, w, 1, rect), []int32{1, 1, h, w}, 2*h, 2*w, 0.5, 0.5, nil)
+	if len(polys) != 1 {
+		t.Fatalf("got %d polygons, want 1", len(polys))
+	}
+	if len(polys[0]) < 4 {
+		t.Errorf("got polygon %v, want at least 4 points", polys[0])
+	}
+	got, want := boxBounds(polys[0]), image.Rect(20, 40, 100, 60)
+	if got.Min.X >= want.Min.X || got.Min.Y >= want.Min.Y || got.Max.X < want.Max.X || got.Max.Y < want.Max.Y {
+		t.Errorf("got %v, want a polygon covering %v", got, want)
+	}
+}
+
+func TestTileStarts(t *testing.T) {
+	tests := []struct {
+		n, size, overlap int
+		want             []int
+	}{
+		{100, 256, 64, []int{0}},
+		{256, 256, 64, []int{0}},
+		{600, 256, 64, []int{0, 192, 344}},
+		{640, 256, 64, []int{0, 192, 384}},
+		{600, 256, 200, []int{0, 128, 256, 344}},
+	}
+	for _, tt := range tests {
+		if got := tileStarts(tt.n, tt.size, tt.overlap); !slices.Equal(got, tt.want) {
+			t.Errorf("tileStarts(%d, %d, %d) = %v, want %v", tt.n, tt.size, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestDetectorRunTiles(t *testing.T) {
+	d, err := newDetector(newTestConfig(t, inkScript(), nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.pool.close()
+
+	// the line crosses all the tiles, the word is split by the seam of the last two tiles.
+	rects := []image.Rectangle{image.Rect(50, 60, 550, 80), image.Rect(420, 120, 460, 135)}
+	img := gocv.Zeros(160, 600, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	for _, r := range rects {
+		gocv.Rectangle(&img, r, color.RGBA{255, 255, 255, 0}, -1)
+	}
+
+	var maps DetectionMaps
+	defer maps.Close()
+	for _, tileSize := range []int{0, 256} {
+		d.tileSize, d.tileOverlap = tileSize, 64
+		boxes, err := d.Run(context.Background(), img, &maps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if maps.Prob.Rows() != img.Rows() || maps.Prob.Cols() != img.Cols() {
+			t.Errorf("tile size %d: got maps of %dx%d, want the size of the image", tileSize, maps.Prob.Cols(), maps.Prob.Rows())
+		}
+		for _, pt := range []image.Point{image.Pt(100, 70), image.Pt(300, 70), image.Pt(500, 70), image.Pt(440, 128)} {
+			if p, b := maps.Prob.GetUCharAt(pt.Y, pt.X), maps.Bitmap.GetUCharAt(pt.Y, pt.X); p != 255 || b != 255 {
+				t.Errorf("tile size %d: got prob %d and bitmap %d at %v, want 255", tileSize, p, b, pt)
+			}
+		}
+		if p, b := maps.Prob.GetUCharAt(20, 300), maps.Bitmap.GetUCharAt(20, 300); p != 0 || b != 0 {
+			t.Errorf("tile size %d: got prob %d and bitmap %d outside the text, want 0", tileSize, p, b)
+		}
+		if len(boxes) != len(rects) {
+			t.Fatalf("tile size %d: got %d boxes, want %d: %v", tileSize, len(boxes), len(rects), boxes)
+		}
+		for i, box := range sortBoxes(boxes) {
+			if got := boxBounds(box); !rects[i].In(got.Inset(-1)) {
+				t.Errorf("tile size %d: box %d: got %v, want a box covering %v", tileSize, i, got, rects[i])
+			}
+		}
+	}
+}
+
+// rectBox returns the box of the rectangle clockwise from its top left point.
+func rectBox(r image.Rectangle) [][]int {
+	return [][]int{{r.Min.X, r.Min.Y}, {r.Max.X, r.Min.Y}, {r.Max.X, r.Max.Y}, {r.Min.X, r.Max.Y}}
+}
+
+func TestDetectorFuseBoxes(t *testing.T) {
+	d := newTestDetector(t)
+	rects := []image.Rectangle{
+		image.Rect(10, 10, 110, 30), image.Rect(12, 8, 112, 32), // the same line at both scales
+		image.Rect(10, 40, 110, 60), image.Rect(12, 42, 50, 58), image.Rect(60, 42, 108, 58), // fragments of a headline
+		image.Rect(200, 10, 300, 30), image.Rect(210, 10, 310, 30), // overlapping boxes of the same scale
+	}
+	scales := []int{0, 1, 0, 1, 1, 0, 0}
+	boxes := make([][][]int, len(rects))
+	for i, r := range rects {
+		boxes[i] = rectBox(r)
+	}
+
+	fused := sortBoxes(d.fuseBoxes(boxes, scales, 320, 80))
+	want := []image.Rectangle{image.Rect(10, 8, 112, 32), image.Rect(200, 10, 300, 30), image.Rect(210, 10, 310, 30), image.Rect(10, 40, 110, 60)}
+	if len(fused) != len(want) {
+		t.Fatalf("got %d boxes, want %d: %v", len(fused), len(want), fused)
+	}
+	for i, box := range fused {
+		if got := boxBounds(box); !want[i].In(got.Inset(-1)) {
+			t.Errorf("box %d: got %v, want a box covering %v", i, got, want[i])
+		}
+	}
+}
+
+func TestDetectorFuseBoxesLines(t *testing.T) {
+	d := newTestDetector(t)
+	// two adjacent lines at the fine scale, merged into a single box at the coarse scale.
+	lines := []image.Rectangle{image.Rect(10, 10, 110, 30), image.Rect(10, 34, 110, 54)}
+	boxes := [][][]int{rectBox(lines[0]), rectBox(lines[1]), rectBox(image.Rect(8, 8, 112, 56))}
+
+	fused := sortBoxes(d.fuseBoxes(boxes, []int{1, 1, 0}, 320, 80))
+	if len(fused) != len(lines) {
+		t.Fatalf("got %d boxes, want the %d lines: %v", len(fused), len(lines), fused)
+	}
+	for i, box := range fused {
+		if got := boxBounds(box); got != lines[i] {
+			t.Errorf("box %d: got %v, want the line %v", i, got, lines[i])
+		}
+	}
+}
+
+func TestDetectorRunScales(t *testing.T) {
+	d, err := newDetector(newTestConfig(t, inkScript(), nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.pool.close()
+	d.scales = []int{320, 640}
+
+	rect := image.Rect(50, 60, 550, 80)
+	img := gocv.Zeros(160, 600, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, rect, color.RGBA{255, 255, 255, 0}, -1)
+
+	boxes, err := d.Run(context.Background(), img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 1 {
+		t.Fatalf("got %d boxes, want the boxes of both scales fused into 1: %v", len(boxes), boxes)
+	}
+	if got := boxBounds(boxes[0]); !rect.In(got.Inset(-1)) {
+		t.Errorf("got %v, want a box covering %v", got, rect)
+	}
+}
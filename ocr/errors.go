@@ -0,0 +1,24 @@
+package ocr
+
+import "errors"
+
+var (
+	// ErrConfigNotFound is returned when the config file does not exist.
+	ErrConfigNotFound = errors.New("ocr: config not found")
+	// ErrInvalidConfig is returned when a config value is out of range, e.g. a non-positive batch size.
+	ErrInvalidConfig = errors.New("ocr: invalid config")
+	// ErrModelNotFound is returned when the model files or the char dict do not exist.
+	ErrModelNotFound = errors.New("ocr: model not found")
+	// ErrDictMismatch is returned when the char dict does not match the recognizer model output.
+	ErrDictMismatch = errors.New("ocr: char dict does not match recognizer model")
+	// ErrUnknownLanguage is returned when the selected language has no recognizer model.
+	ErrUnknownLanguage = errors.New("ocr: unknown language")
+	// ErrImageDecode is returned when the image could not be read or decoded.
+	ErrImageDecode = errors.New("ocr: image decode failed")
+	// ErrEmptyInput is returned when the input image is empty.
+	ErrEmptyInput = errors.New("ocr: empty input")
+	// ErrPoolTimeout is returned when no predictor became available within the pool timeout.
+	ErrPoolTimeout = errors.New("ocr: predictor pool timeout")
+	// ErrClosed is returned when the engine or predictor is used after Close.
+	ErrClosed = errors.New("ocr: closed")
+)
@@ -0,0 +1,211 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"math"
+
+	clipper "github.com/ctessum/go.clipper"
+	"gocv.io/x/gocv"
+)
+
+// runScales detects the text at each scale of the detector, then fuses the boxes of different scales
+// covering the same text, so that both large and small text are detected at a suitable resolution.
+func (d *detector) runScales(ctx context.Context, model *Predictor, img gocv.Mat, maps *DetectionMaps) ([][][]int, error) {
+	var (
+		boxes  [][][]int
+		scales []int
+	)
+	for i, sideLen := range d.scales {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		scaleBoxes, err := d.predict(model, img, d.ratio(img, sideLen), maps)
+		if err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, scaleBoxes...)
+		for range scaleBoxes {
+			scales = append(scales, i)
+		}
+	}
+	return d.fuseBoxes(boxes, scales, img.Cols(), img.Rows()), nil
+}
+
+// fuseBoxes merges the boxes of different scales on the same text line whose IoU reaches the fusion IoU threshold,
+// or of which the smaller is covered by the other at least by the fusion contain threshold.
+// A box covering several lines split at another scale is dropped for the finer split.
+func (d *detector) fuseBoxes(boxes [][][]int, scales []int, w, h int) [][][]int {
+	paths := make([]clipper.Path, len(boxes))
+	bounds := make([]image.Rectangle, len(boxes))
+	for i, box := range boxes {
+		paths[i] = toPath(box)
+		bounds[i] = polyBounds(box)
+	}
+
+	var (
+		kept       [][][]int
+		keptScales []int
+	)
+	for i := range boxes {
+		lines := 0
+		for j := range boxes {
+			if scales[i] == scales[j] || !bounds[i].Overlaps(bounds[j]) || sameLine(bounds[i], bounds[j]) {
+				continue
+			}
+			if inter, _, area := overlap(paths[i], paths[j]); inter/max(area, 1) >= d.fusionContain {
+				lines++
+			}
+		}
+		if lines < 2 {
+			kept = append(kept, boxes[i])
+			keptScales = append(keptScales, scales[i])
+		}
+	}
+
+	return d.mergeBoxes(kept, w, h, func(i, j int, a, b clipper.Path) bool {
+		if keptScales[i] == keptScales[j] || !sameLine(polyBounds(kept[i]), polyBounds(kept[j])) {
+			return false
+		}
+		inter, areaA, areaB := overlap(a, b)
+		if inter == 0 {
+			return false
+		}
+		iou := inter / max(areaA+areaB-inter, 1)
+		return iou >= d.fusionIoU || inter/max(min(areaA, areaB), 1) >= d.fusionContain
+	})
+}
+
+// overlap returns the intersection area of the paths and their areas.
+func overlap(a, b clipper.Path) (float64, float64, float64) {
+	inter := pathsArea(clipPaths(clipper.CtIntersection, clipper.Paths{a}, clipper.Paths{b}))
+	return inter, math.Abs(clipper.Area(a)), math.Abs(clipper.Area(b))
+}
+
+// sameLine reports whether the boxes of bounds `a` and `b` are on the same text line: across the direction
+// of the smaller box, their sizes differ by less than 5:3 and their centers by at most a quarter of the larger size.
+func sameLine(a, b image.Rectangle) bool {
+	if a.Dx()*a.Dy() < b.Dx()*b.Dy() {
+		a, b = b, a
+	}
+	// vertical text lines are compared across their width.
+	if b.Dy() > b.Dx() {
+		a = image.Rect(a.Min.Y, a.Min.X, a.Max.Y, a.Max.X)
+		b = image.Rect(b.Min.Y, b.Min.X, b.Max.Y, b.Max.X)
+	}
+	hi, lo := max(a.Dy(), b.Dy()), min(a.Dy(), b.Dy())
+	dc := (a.Min.Y + a.Max.Y) - (b.Min.Y + b.Max.Y)
+	return 5*lo >= 3*hi && 2*max(dc, -dc) <= hi
+}
+
+// mergeBoxes merges the groups of boxes connected by `same` into the minimum area box
+// (or the polygon in BoxTypePoly) of their union, the other boxes are kept as is.
+// `same` is only called for boxes whose bounding rectangles overlap.
+func (d *detector) mergeBoxes(boxes [][][]int, w, h int, same func(i, j int, a, b clipper.Path) bool) [][][]int {
+	parent := make([]int, len(boxes))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	paths := make([]clipper.Path, len(boxes))
+	bounds := make([]image.Rectangle, len(boxes))
+	for i, box := range boxes {
+		paths[i] = toPath(box)
+		bounds[i] = polyBounds(box)
+	}
+	for i := range boxes {
+		for j := i + 1; j < len(boxes); j++ {
+			if find(i) != find(j) && bounds[i].Overlaps(bounds[j]) && same(i, j, paths[i], paths[j]) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int]clipper.Paths, len(boxes))
+	var roots []int
+	for i := range boxes {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], paths[i])
+	}
+
+	merged := make([][][]int, 0, len(roots))
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) == 1 {
+			merged = append(merged, boxes[root])
+			continue
+		}
+		poly := unionPolygon(group, w, h)
+		if len(poly) < 3 {
+			continue
+		}
+		if d.boxType == BoxTypePoly {
+			merged = append(merged, poly)
+		} else {
+			merged = append(merged, polygonBox(poly, w, h))
+		}
+	}
+	return merged
+}
+
+// unionPolygon returns the largest polygon of the union of the paths clipped into the image of size `w` x `h`.
+func unionPolygon(paths clipper.Paths, w, h int) [][]int {
+	var (
+		largest clipper.Path
+		area    float64
+	)
+	for _, path := range clipPaths(clipper.CtUnion, paths, nil) {
+		if a := math.Abs(clipper.Area(path)); a > area {
+			largest, area = path, a
+		}
+	}
+	poly := make([][]int, len(largest))
+	for i, p := range largest {
+		poly[i] = []int{clamp(int(p.X), 0, w-1), clamp(int(p.Y), 0, h-1)}
+	}
+	return poly
+}
+
+// clipPaths returns the result of the clipping operation `op` on the closed paths.
+func clipPaths(op clipper.ClipType, subject, clip clipper.Paths) clipper.Paths {
+	c := clipper.NewClipper(clipper.IoNone)
+	c.AddPaths(subject, clipper.PtSubject, true)
+	if len(clip) > 0 {
+		c.AddPaths(clip, clipper.PtClip, true)
+	}
+	soln, _ := c.Execute1(op, clipper.PftNonZero, clipper.PftNonZero)
+	return soln
+}
+
+// pathsArea returns the area covered by the paths.
+func pathsArea(paths clipper.Paths) float64 {
+	return math.Abs(clipper.AreaCombined(paths))
+}
+
+// toPath converts the points of a box or polygon to a clipper path.
+func toPath(poly [][]int) clipper.Path {
+	path := make(clipper.Path, len(poly))
+	for i, pt := range poly {
+		path[i] = &clipper.IntPoint{X: clipper.CInt(pt[0]), Y: clipper.CInt(pt[1])}
+	}
+	return path
+}
+
+// polyBounds returns the bounding rectangle of the box or polygon, including its max point.
+func polyBounds(poly [][]int) image.Rectangle {
+	r := image.Rectangle{Min: image.Pt(poly[0][0], poly[0][1]), Max: image.Pt(poly[0][0]+1, poly[0][1]+1)}
+	for _, pt := range poly[1:] {
+		r = r.Union(image.Rect(pt[0], pt[1], pt[0]+1, pt[1]+1))
+	}
+	return r
+}
@@ -0,0 +1,223 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// langClassifier identifies the language of the text images.
+// The language classification model of PaddleClas is supported.
+// https://github.com/PaddlePaddle/PaddleClas/blob/release/2.5/docs/en/PULC/PULC_language_classification_en.md
+type langClassifier struct {
+	pool     *predictorPool
+	labels   []string
+	thresh   float32
+	batchNum int
+	shape    []int
+
+	mean    []float32
+	scale   []float32
+	isScale bool
+}
+
+// newLangClassifier creates the language classifier, it returns nil if the language
+// identification is disabled or identifies the language by score.
+func newLangClassifier(cfg *Config) (*langClassifier, error) {
+	lcfg := cfg.LanguageID
+	if !lcfg.Enabled || lcfg.ModelDir == "" {
+		return nil, nil
+	}
+	pool, err := newPredictorPool(&cfg.Predictor, lcfg.ModelDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the labels are replaced by their languages, the ones without recognizer are reported once.
+	labels := make([]string, len(lcfg.Labels))
+	var unknown []string
+	for i, label := range lcfg.Labels {
+		labels[i] = label
+		if lang, ok := lcfg.LabelLanguages[label]; ok {
+			labels[i] = lang
+		}
+		if _, ok := cfg.Languages[labels[i]]; !ok && labels[i] != cfg.Recognizer.Language {
+			unknown = append(unknown, labels[i])
+		}
+	}
+	if len(unknown) > 0 {
+		log.Printf("language classifier: no recognizer of %v, using the default language %s\n", unknown, cfg.Recognizer.Language)
+	}
+	return &langClassifier{
+		pool:     pool,
+		labels:   labels,
+		thresh:   lcfg.Thresh,
+		batchNum: lcfg.BatchNum,
+		shape:    lcfg.ImageShape,
+
+		mean:    []float32{0.485, 0.456, 0.406},
+		scale:   []float32{1 / 0.229, 1 / 0.224, 1 / 0.225},
+		isScale: true,
+	}, nil
+}
+
+// run returns the language of each text image, or "" if it is not identified.
+func (p *langClassifier) run(ctx context.Context, imgs []gocv.Mat) ([]string, error) {
+	t := time.Now()
+	langs := make([]string, len(imgs))
+	c, h, w := p.shape[0], p.shape[1], p.shape[2]
+
+	model, err := p.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.pool.put(model)
+	for i := 0; i < len(imgs); i += p.batchNum {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		j := min(i+p.batchNum, len(imgs))
+
+		normImgs := make([]gocv.Mat, 0, j-i)
+		for k := i; k < j; k++ {
+			resizeImg := gocv.NewMat()
+			defer resizeImg.Close()
+			gocv.Resize(imgs[k], &resizeImg, image.Pt(w, h), 0, 0, gocv.InterpolationLinear)
+
+			normalize(resizeImg, p.mean, p.scale, p.isScale)
+			normImgs = append(normImgs, resizeImg)
+		}
+
+		predicts, shape, err := model.Run([]int32{int32(j - i), int32(c), int32(h), int32(w)}, permuteBatch(normImgs))
+		if err != nil {
+			return nil, err
+		}
+
+		for m := 0; m < int(shape[0]); m++ {
+			label, score := argmax(predicts[m*int(shape[1]) : (m+1)*int(shape[1])])
+			if score >= p.thresh && label < len(p.labels) {
+				langs[i+m] = p.labels[label]
+			}
+		}
+	}
+	log.Printf("language classifier: box num: %d, elapsed: %dms\n", len(langs), time.Since(t).Milliseconds())
+	return langs, nil
+}
+
+// newLanguageRecognizer creates the recognizer of language `lang`,
+// sharing the recognizer settings of `cfg` missing in `lcfg`.
+func newLanguageRecognizer(cfg *Config, lang string, lcfg LanguageConfig) (*recognizer, error) {
+	c := *cfg
+	c.Recognizer.Language = lang
+	c.Recognizer.ModelDir = lcfg.ModelDir
+	c.Recognizer.CharDictPath = lcfg.CharDictPath
+	c.Recognizer.LexiconPath = lcfg.LexiconPath
+	c.Recognizer.LanguageModel = nil
+	if len(lcfg.ImageShape) > 0 {
+		c.Recognizer.ImageShape = lcfg.ImageShape
+	}
+	return newRecognizer(&c)
+}
+
+// languageRecognizers returns the recognizers of the languages selected by `opts`.
+// If no language is selected, it returns the recognizers of all languages
+// when the language is identified by score, or the default recognizer otherwise.
+func (o *impl) languageRecognizers(opts *predictOptions) ([]*recognizer, error) {
+	langs := opts.languages
+	if len(langs) == 0 {
+		if !o.langID || o.langClassifier != nil {
+			return []*recognizer{o.recognizer}, nil
+		}
+		langs = o.languages
+	}
+	recs := make([]*recognizer, 0, len(langs))
+	for _, lang := range langs {
+		rec, ok := o.recognizers[lang]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, lang)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// recognizeLanguages recognizes the text images with each of `recs`,
+// keeping the results of the recognizer with the highest score for each image.
+func recognizeLanguages(ctx context.Context, recs []*recognizer, imgs []gocv.Mat, bboxes [][][]int, dirs []Direction, crops []*cropInfo, opts *predictOptions) ([][]Result, error) {
+	var best [][]Result
+	for _, rec := range recs {
+		results, err := rec.recognize(ctx, imgs, bboxes, dirs, crops, opts)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil {
+			best = results
+			continue
+		}
+		for i := range results {
+			if meanScore(results[i]) > meanScore(best[i]) {
+				best[i] = results[i]
+			}
+		}
+	}
+	return best, nil
+}
+
+// recognizeIdentified recognizes each text image with the recognizer of the language
+// identified by the language classifier.
+func (o *impl) recognizeIdentified(ctx context.Context, imgs []gocv.Mat, bboxes [][][]int, dirs []Direction, crops []*cropInfo, opts *predictOptions) ([][]Result, error) {
+	langs, err := o.langClassifier.run(ctx, imgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the indexes of the text images of each recognizer, in the order of the languages.
+	groups := make(map[*recognizer][]int)
+	var order []*recognizer
+	for i, lang := range langs {
+		rec, ok := o.recognizers[lang]
+		if !ok {
+			rec = o.recognizer
+		}
+		if _, ok := groups[rec]; !ok {
+			order = append(order, rec)
+		}
+		groups[rec] = append(groups[rec], i)
+	}
+
+	results := make([][]Result, len(imgs))
+	for _, rec := range order {
+		idxs := groups[rec]
+		groupImgs := make([]gocv.Mat, len(idxs))
+		groupBoxes := make([][][]int, len(idxs))
+		groupDirs := make([]Direction, len(idxs))
+		groupCrops := make([]*cropInfo, len(idxs))
+		for k, i := range idxs {
+			groupImgs[k], groupBoxes[k], groupDirs[k], groupCrops[k] = imgs[i], bboxes[i], dirs[i], crops[i]
+		}
+		groupResults, err := rec.recognize(ctx, groupImgs, groupBoxes, groupDirs, groupCrops, opts)
+		if err != nil {
+			return nil, err
+		}
+		for k, i := range idxs {
+			results[i] = groupResults[k]
+		}
+	}
+	return results, nil
+}
+
+// meanScore returns the mean score of the results of a text image.
+func meanScore(results []Result) float32 {
+	if len(results) == 0 {
+		return 0
+	}
+	var sum float32
+	for _, res := range results {
+		sum += res.Score
+	}
+	return sum / float32(len(results))
+}
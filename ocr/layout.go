@@ -0,0 +1,218 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Region is a region of the page found by the layout detector, e.g. a title, a table or a figure.
+type Region struct {
+	Label   string  `json:"label"`             // Label of the region, from `layout.labels`
+	Score   float32 `json:"score"`             // Confidence of the region
+	BBox    [][]int `json:"bbox"`              // Box of the region, clockwise from the top left point
+	Results []int   `json:"results,omitempty"` // Indexes of the results in Page.Results inside the region
+}
+
+// layoutDetector finds the layout regions of pages.
+// The PicoDet layout models of PP-Structure exported without NMS are supported,
+// their outputs are the class scores of each stride followed by the box distributions of each stride.
+// https://github.com/PaddlePaddle/PaddleOCR/blob/release/2.7/ppstructure/layout/README.md
+type layoutDetector struct {
+	pool      *predictorPool
+	labels    []string
+	thresh    float32
+	nmsThresh float64
+	strides   []int
+	shape     []int
+
+	mean    []float32
+	scale   []float32
+	isScale bool
+}
+
+// layoutBox is a candidate region in the coordinates of the model input.
+type layoutBox struct {
+	label int
+	score float32
+	box   [4]float64 // xmin, ymin, xmax, ymax
+}
+
+// newLayoutDetector creates the layout detector, it returns nil if it is disabled.
+func newLayoutDetector(cfg *Config) (*layoutDetector, error) {
+	lcfg := cfg.Layout
+	if !lcfg.Enabled {
+		return nil, nil
+	}
+	pool, err := newPredictorPool(&cfg.Predictor, lcfg.ModelDir)
+	if err != nil {
+		return nil, err
+	}
+	return &layoutDetector{
+		pool:      pool,
+		labels:    lcfg.Labels,
+		thresh:    lcfg.Thresh,
+		nmsThresh: lcfg.NMSThresh,
+		strides:   lcfg.FPNStrides,
+		shape:     lcfg.ImageShape,
+
+		mean:    []float32{0.485, 0.456, 0.406},
+		scale:   []float32{1 / 0.229, 1 / 0.224, 1 / 0.225},
+		isScale: true,
+	}, nil
+}
+
+// run returns the layout regions of the image sorted from top to bottom and left to right.
+func (l *layoutDetector) run(ctx context.Context, img gocv.Mat) ([]Region, error) {
+	t := time.Now()
+	c, h, w := l.shape[0], l.shape[1], l.shape[2]
+
+	resizeImg := gocv.NewMat()
+	defer resizeImg.Close()
+	gocv.Resize(img, &resizeImg, image.Pt(w, h), 0, 0, gocv.InterpolationLinear)
+	normalize(resizeImg, l.mean, l.scale, l.isScale)
+
+	model, err := l.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer l.pool.put(model)
+
+	outputs, shapes, err := model.RunOutputs([]int32{1, int32(c), int32(h), int32(w)}, permute(resizeImg))
+	if err != nil {
+		return nil, err
+	}
+	regions, err := l.postProcess(outputs, shapes, img.Rows(), img.Cols())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("layout detector: num regions: %d, elapsed: %dms\n", len(regions), time.Since(t).Milliseconds())
+	return regions, nil
+}
+
+// postProcess decodes the regions from the outputs of the model, suppressing the overlapping regions
+// of each label and scaling them to the source image of size `oriW` x `oriH`.
+// Refer: PicodetPostProcessor of PaddleOCR C++ deployment.
+func (l *layoutDetector) postProcess(outputs [][]float32, shapes [][]int32, oriH, oriW int) ([]Region, error) {
+	n := len(l.strides)
+	if len(outputs) != 2*n {
+		return nil, fmt.Errorf("layout detector: got %d outputs, want %d for strides %v", len(outputs), 2*n, l.strides)
+	}
+	h, w := l.shape[1], l.shape[2]
+	numClass := int(shapes[0][2])
+	regMax := int(shapes[n][2]) / 4
+
+	byLabel := make(map[int][]layoutBox)
+	for i, stride := range l.strides {
+		scores, dists := outputs[i], outputs[n+i]
+		featW := (w + stride - 1) / stride
+		for idx := 0; idx < len(scores)/numClass && (idx+1)*4*regMax <= len(dists); idx++ {
+			label, score := argmax(scores[idx*numClass : (idx+1)*numClass])
+			if score <= l.thresh {
+				continue
+			}
+			ctX, ctY := (float64(idx%featW)+0.5)*float64(stride), (float64(idx/featW)+0.5)*float64(stride)
+			var dis [4]float64
+			for k := range dis {
+				dis[k] = distance(dists[(idx*4+k)*regMax:(idx*4+k+1)*regMax]) * float64(stride)
+			}
+			byLabel[label] = append(byLabel[label], layoutBox{label: label, score: score, box: [4]float64{
+				max(ctX-dis[0], 0), max(ctY-dis[1], 0), min(ctX+dis[2], float64(w)), min(ctY+dis[3], float64(h)),
+			}})
+		}
+	}
+
+	ratioW, ratioH := float64(oriW)/float64(w), float64(oriH)/float64(h)
+	var regions []Region
+	for _, boxes := range byLabel {
+		for _, b := range nms(boxes, l.nmsThresh) {
+			x0 := clamp(int(math.Round(b.box[0]*ratioW)), 0, oriW-1)
+			y0 := clamp(int(math.Round(b.box[1]*ratioH)), 0, oriH-1)
+			x1 := clamp(int(math.Round(b.box[2]*ratioW)), 0, oriW-1)
+			y1 := clamp(int(math.Round(b.box[3]*ratioH)), 0, oriH-1)
+			regions = append(regions, Region{
+				Label: l.label(b.label),
+				Score: b.score,
+				BBox:  [][]int{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}},
+			})
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		a, b := regions[i].BBox[0], regions[j].BBox[0]
+		return a[1] < b[1] || a[1] == b[1] && a[0] < b[0]
+	})
+	return regions, nil
+}
+
+// label returns the name of the label, or its index if it has no name.
+func (l *layoutDetector) label(label int) string {
+	if label < len(l.labels) {
+		return l.labels[label]
+	}
+	return strconv.Itoa(label)
+}
+
+// distance returns the expected distance in strides of the softmax distribution of the logits.
+func distance(logits []float32) float64 {
+	_, m := argmax(logits)
+	var sum, dis float64
+	for j, v := range logits {
+		e := math.Exp(float64(v - m))
+		sum += e
+		dis += float64(j) * e
+	}
+	return dis / sum
+}
+
+// nms returns the boxes by descending score, dropping the boxes whose IoU with a kept box exceeds `thresh`.
+func nms(boxes []layoutBox, thresh float64) []layoutBox {
+	sort.SliceStable(boxes, func(i, j int) bool { return boxes[i].score > boxes[j].score })
+	area := func(b [4]float64) float64 { return max(b[2]-b[0]+1, 0) * max(b[3]-b[1]+1, 0) }
+
+	var kept []layoutBox
+	for _, b := range boxes {
+		keep := true
+		for _, k := range kept {
+			inter := area([4]float64{max(b.box[0], k.box[0]), max(b.box[1], k.box[1]), min(b.box[2], k.box[2]), min(b.box[3], k.box[3])})
+			if inter/(area(b.box)+area(k.box)-inter) > thresh {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+// assignRegions assigns each result to the region covering most of its box,
+// if the region covers at least half of it, setting Result.Layout and Region.Results.
+func assignRegions(results []Result, regions []Region) {
+	rects := make([]image.Rectangle, len(regions))
+	for i, r := range regions {
+		rects[i] = polyBounds(r.BBox)
+	}
+	for i := range results {
+		box := polyBounds(results[i].BBox)
+		best, bestArea := -1, 0
+		for j, rect := range rects {
+			inter := box.Intersect(rect)
+			if area := inter.Dx() * inter.Dy(); area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		if best < 0 || 2*bestArea < box.Dx()*box.Dy() {
+			continue
+		}
+		results[i].Layout = regions[best].Label
+		regions[best].Results = append(regions[best].Results, i)
+	}
+}
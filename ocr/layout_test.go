@@ -0,0 +1,75 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestLayoutPostProcess(t *testing.T) {
+	l := &layoutDetector{
+		labels:    []string{"text", "title", "list", "table", "figure"},
+		thresh:    0.4,
+		nmsThresh: 0.5,
+		strides:   []int{8, 16, 32, 64},
+		shape:     []int{3, 256, 256},
+	}
+	script := layoutScript(5,
+		layoutRegion{label: 1, score: 0.9, stride: 2, rect: image.Rect(16, 16, 240, 80)},
+		// the same title predicted at a finer stride is suppressed.
+		layoutRegion{label: 1, score: 0.6, stride: 1, rect: image.Rect(16, 16, 240, 80)},
+		layoutRegion{label: 3, score: 0.8, stride: 2, rect: image.Rect(16, 112, 240, 240)},
+		layoutRegion{label: 4, score: 0.3, stride: 3, rect: image.Rect(0, 0, 128, 128)},
+	)
+	outputs, shapes := script([]int32{1, 3, 256, 256}, nil)
+
+	// the regions are scaled to the source image of twice the input size.
+	regions, err := l.postProcess(outputs, shapes, 512, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Region{
+		{Label: "title", Score: 0.9, BBox: [][]int{{32, 32}, {480, 32}, {480, 160}, {32, 160}}},
+		{Label: "table", Score: 0.8, BBox: [][]int{{32, 224}, {480, 224}, {480, 480}, {32, 480}}},
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("got regions %+v, want %+v", regions, want)
+	}
+
+	if _, err := l.postProcess(outputs[:4], shapes[:4], 512, 512); err == nil {
+		t.Error("got no error for missing outputs")
+	}
+}
+
+func TestPredictPageLayout(t *testing.T) {
+	title, body := image.Rect(8, 8, 56, 24), image.Rect(8, 40, 120, 56)
+	cfg := newTestConfig(t, probMapScript(title, body), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+	fakeModels["layout"] = layoutScript(5, layoutRegion{label: 1, score: 0.9, stride: 0, rect: image.Rect(4, 4, 68, 36)})
+	cfg.Layout.Enabled = true
+	cfg.Layout.ModelDir = "layout"
+	cfg.Layout.ImageShape = []int{3, 64, 128}
+	o, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	page, err := o.PredictPage(context.Background(), img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 2 || len(page.Regions) != 1 {
+		t.Fatalf("got results %+v and regions %+v, want 2 results in a region", page.Results, page.Regions)
+	}
+	if r := page.Regions[0]; r.Label != "title" || !reflect.DeepEqual(r.Results, []int{0}) {
+		t.Errorf("got region %+v, want a title of result 0", r)
+	}
+	if page.Results[0].Layout != "title" || page.Results[1].Layout != "" {
+		t.Errorf("got layouts %q and %q, want title and none", page.Results[0].Layout, page.Results[1].Layout)
+	}
+}
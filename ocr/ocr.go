@@ -1,9 +1,12 @@
 package ocr
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
-	"log"
+	"io"
 	"math"
 	"slices"
 	"sort"
@@ -12,80 +15,480 @@ import (
 )
 
 // OCR is the OCR engine.
+// It is safe for concurrent use by multiple goroutines, each model runs at most
+// `predictor.pool_size` requests in parallel.
 type OCR interface {
-	Predict(img gocv.Mat) []Result
-	ReadImage(name string) gocv.Mat
+	Predict(img gocv.Mat, opts ...PredictOption) ([]Result, error)
+	PredictContext(ctx context.Context, img gocv.Mat, opts ...PredictOption) ([]Result, error)
+	PredictImage(img image.Image, opts ...PredictOption) ([]Result, error)
+	PredictBytes(buf []byte, opts ...PredictOption) ([]Result, error)
+	PredictReader(r io.Reader, opts ...PredictOption) ([]Result, error)
+	// PredictPage predicts the text in the image like PredictContext, with the page level results.
+	PredictPage(ctx context.Context, img gocv.Mat, opts ...PredictOption) (*Page, error)
+	// Detect finds the text regions in the image in reading order, without recognizing them.
+	Detect(ctx context.Context, img gocv.Mat, opts ...PredictOption) ([]Box, error)
+	// Classify predicts the directions of the text images and rotates the upside down ones in place,
+	// the directions are zero if the classifier is disabled.
+	Classify(ctx context.Context, crops []gocv.Mat) ([]Direction, error)
+	// Recognize recognizes the text images of single horizontal text lines, e.g. cropped by the boxes of a template.
+	// It returns the results of each text image, a single one unless the line is split by `recognizer.split_long_text`,
+	// the boxes of the results are in the coordinates of their text image.
+	Recognize(ctx context.Context, crops []gocv.Mat, opts ...PredictOption) ([][]Result, error)
+	ReadImage(name string) (gocv.Mat, error)
+	// Close releases the models of the engine.
+	// Calls in progress finish normally, later calls return ErrClosed.
+	Close() error
 }
 
 // Result is the OCR predict result.
 // OCR result of a single image may contains multiple `Result`s,
 type Result struct {
-	Text      string    `json:"text"`      // Predicted text
-	BBox      [][]int   `json:"bbox"`      // BBox box of the predicted text
-	Score     float32   `json:"score"`     // Score of the predicted text
-	Direction Direction `json:"direction"` // Direction of the predicted text (if classifier is enabled)
+	Text      string    `json:"text"`               // Predicted text
+	BBox      [][]int   `json:"bbox"`               // BBox box of the predicted text
+	Polygon   [][]int   `json:"polygon,omitempty"`  // Polygon of the predicted text (if `detector.box_type` is poly)
+	Score     float32   `json:"score"`              // Score of the predicted text
+	Direction Direction `json:"direction"`          // Direction of the predicted text (if classifier is enabled)
+	Chars     []Char    `json:"chars,omitempty"`    // Characters of the predicted text (if `recognizer.return_chars` is enabled)
+	Language  string    `json:"language,omitempty"` // Language of the recognizer model
+	// Vertical reports that the text is a vertical column read from top to bottom.
+	Vertical bool `json:"vertical,omitempty"`
+	// Layout is the label of the layout region of the text, e.g. title or table, if `layout` is enabled.
+	Layout string `json:"layout,omitempty"`
+	// LongText reports that the text line exceeded `recognizer.max_text_length` and was truncated or split.
+	// The parts of a split line are consecutive in reading order and share its Polygon, without Alternatives.
+	LongText bool `json:"long_text,omitempty"`
+	// Alternatives are the `recognizer.top_k` best readings of beam search, best first.
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+}
+
+// Page is the OCR result of a whole image.
+type Page struct {
+	Results []Result `json:"results"` // Results of the text lines
+	// Rotation is the clockwise angle in degrees (0, 90, 180 or 270) the content of the image was rotated by,
+	// if `orientation` is enabled. The image is rotated upright before detection, then the boxes are mapped
+	// back to the source image, each starting at the top left point of the upright text.
+	Rotation int `json:"rotation"`
+	// Skew is the clockwise angle in degrees of the text lines corrected by `deskew`,
+	// the boxes of the results are mapped back to the image before deskewing.
+	Skew float64 `json:"skew"`
+	// Columns are the results grouped into columns, paragraphs and lines in reading order,
+	// if `reading_order` is enabled. The results are then sorted in reading order.
+	Columns []Column `json:"columns,omitempty"`
+	// Regions are the layout regions of the page, if `layout` is enabled.
+	Regions []Region `json:"regions,omitempty"`
+}
+
+// Box is a text region found by the detector.
+type Box struct {
+	BBox    [][]int `json:"bbox"`              // Box of the text, clockwise from the top left point
+	Polygon [][]int `json:"polygon,omitempty"` // Polygon of the text (if `detector.box_type` is poly)
+}
+
+// Alternative is a candidate reading of the text.
+type Alternative struct {
+	Text     string  `json:"text"`      // Candidate text
+	Score    float32 `json:"score"`     // Mean probability of the characters, as Result.Score
+	LogScore float64 `json:"log_score"` // Log probability of the text over all CTC alignments, plus the weighted language model score
+}
+
+// Char is a single character of the predicted text.
+type Char struct {
+	Text  string  `json:"text"`  // Predicted character
+	Score float32 `json:"score"` // CTC probability of the character
+	BBox  [][]int `json:"bbox"`  // Approximate box of the character, derived from its CTC time steps
 }
 
 type impl struct {
-	detector   *detector
-	classifier *classifier
-	recognizer *recognizer
+	detector    *detector
+	classifier  *classifier
+	recognizer  *recognizer            // recognizer of the default language
+	recognizers map[string]*recognizer // recognizers of all languages by name
+	languages   []string               // names of all languages, the default one first
+
+	langID         bool            // identify the language of each text line
+	langClassifier *langClassifier // nil if the language is identified by score
+
+	orientation *orientationClassifier // nil if the page orientation is not classified
+	deskew      *deskewer              // nil if the page is not deskewed
+	readOrder   *readingOrder          // nil if the results are not sorted in reading order
+	layout      *layoutDetector        // nil if the layout is not detected
+
+	verticalText string // recognition mode of vertical text columns
 }
 
 // New creates a new OCR engine using the config file specified by `conf`.
-func New(conf string) (OCR, error) {
+func New(conf string, opts ...Option) (OCR, error) {
 	cfg, err := ReadConfig(conf)
 	if err != nil {
-		log.Panicf("Read config %v error: %v\n", conf, err)
+		return nil, err
+	}
+	return NewWithConfig(cfg, opts...)
+}
+
+// NewWithConfig creates a new OCR engine using `cfg` customized by `opts`.
+// `cfg` itself is not modified or retained, except its LanguageModel.
+// It returns ErrInvalidConfig for non-positive batch sizes or malformed image shapes.
+func NewWithConfig(cfg *Config, opts ...Option) (OCR, error) {
+	if cfg == nil {
+		return nil, errors.New("ocr: nil config")
+	}
+	cfg = cfg.clone()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := cfg.Languages[cfg.Recognizer.Language]; ok {
+		return nil, fmt.Errorf("ocr: duplicate language %q", cfg.Recognizer.Language)
+	}
+
+	var err error
+	o := &impl{
+		recognizers: make(map[string]*recognizer, len(cfg.Languages)+1),
+		languages:   []string{cfg.Recognizer.Language},
+		langID:      cfg.LanguageID.Enabled,
+		deskew:      newDeskewer(cfg),
+		readOrder:   newReadingOrder(cfg),
+
+		verticalText: cfg.Recognizer.VerticalText,
+	}
+	if o.detector, err = newDetector(cfg); err != nil {
+		return nil, err
+	}
+	if o.recognizer, err = newRecognizer(cfg); err != nil {
+		o.Close()
+		return nil, err
+	}
+	o.recognizers[cfg.Recognizer.Language] = o.recognizer
+	for lang, lcfg := range cfg.Languages {
+		rec, err := newLanguageRecognizer(cfg, lang, lcfg)
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("language %s: %w", lang, err)
+		}
+		o.recognizers[lang] = rec
+		o.languages = append(o.languages, lang)
+	}
+	slices.Sort(o.languages[1:])
+	if o.classifier, err = newClassifier(cfg); err != nil {
+		o.Close()
+		return nil, err
+	}
+	if o.langClassifier, err = newLangClassifier(cfg); err != nil {
+		o.Close()
+		return nil, err
 	}
+	if o.orientation, err = newOrientationClassifier(cfg); err != nil {
+		o.Close()
+		return nil, err
+	}
+	if o.layout, err = newLayoutDetector(cfg); err != nil {
+		o.Close()
+		return nil, err
+	}
+	return o, nil
+}
 
-	detector, err := newDetector(cfg)
+// Predict predicts the text in the image.
+func (o *impl) Predict(img gocv.Mat, opts ...PredictOption) ([]Result, error) {
+	return o.PredictContext(context.Background(), img, opts...)
+}
+
+// PredictContext predicts the text in the image.
+// It stops between the pipeline stages and recognizer batches once `ctx` is done,
+// and returns `ctx.Err()`.
+func (o *impl) PredictContext(ctx context.Context, img gocv.Mat, opts ...PredictOption) ([]Result, error) {
+	page, err := o.PredictPage(ctx, img, opts...)
 	if err != nil {
 		return nil, err
 	}
-	recognizer, err := newRecognizer(cfg)
+	return page.Results, nil
+}
+
+// PredictPage predicts the text in the image like PredictContext, with the page level results.
+func (o *impl) PredictPage(ctx context.Context, img gocv.Mat, opts ...PredictOption) (*Page, error) {
+	po := newPredictOptions(opts)
+	if isEmpty(img) {
+		return nil, ErrEmptyInput
+	}
+	recs, err := o.languageRecognizers(po)
 	if err != nil {
 		return nil, err
 	}
-	classifier, err := newClassifier(cfg)
+
+	page := &Page{}
+	srcW, srcH := img.Cols(), img.Rows()
+	if o.orientation != nil {
+		if page.Rotation, err = o.orientation.run(ctx, img); err != nil {
+			return nil, err
+		}
+		if page.Rotation != 0 {
+			upright := rotateUpright(img, page.Rotation)
+			defer upright.Close()
+			img = upright
+		}
+	}
+	if o.deskew != nil {
+		page.Results, page.Skew, err = o.predictDeskewed(ctx, img, recs, po)
+	} else {
+		page.Results, err = o.predict(ctx, img, recs, po)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.readOrder != nil {
+		page.Columns = o.readOrder.order(page.Results)
+	}
+	if o.layout != nil {
+		if page.Regions, err = o.layout.run(ctx, img); err != nil {
+			return nil, err
+		}
+		assignRegions(page.Results, page.Regions)
+	}
+	// the page is laid out upright, then its boxes are mapped back to the source image.
+	if page.Rotation != 0 {
+		remapPage(page, uprightTransform(page.Rotation, srcW, srcH), srcW, srcH)
+	}
+	return page, nil
+}
+
+// predict detects and recognizes the text in the image with the recognizers `recs`.
+func (o *impl) predict(ctx context.Context, img gocv.Mat, recs []*recognizer, po *predictOptions) ([]Result, error) {
+	boxes, polys, err := o.detect(ctx, img, po)
 	if err != nil {
 		return nil, err
 	}
+	return o.recognizeBoxes(ctx, img, boxes, polys, recs, po)
+}
 
-	return &impl{
-		detector:   detector,
-		recognizer: recognizer,
-		classifier: classifier,
-	}, nil
+// predictDeskewed is like predict, but if the text lines are skewed, it rotates the image to make them
+// horizontal and predicts the text in the rotated image. The boxes of the results are in the source image.
+// It returns the clockwise skew in degrees of the text lines.
+func (o *impl) predictDeskewed(ctx context.Context, img gocv.Mat, recs []*recognizer, po *predictOptions) ([]Result, float64, error) {
+	boxes, polys, err := o.detect(ctx, img, po)
+	if err != nil {
+		return nil, 0, err
+	}
+	skew := o.deskew.angle(boxes)
+	if skew == 0 {
+		results, err := o.recognizeBoxes(ctx, img, boxes, polys, recs, po)
+		return results, 0, err
+	}
+
+	rotated, t := rotateImage(img, skew)
+	defer rotated.Close()
+	results, err := o.predict(ctx, rotated, recs, po)
+	if err != nil {
+		return nil, 0, err
+	}
+	remapResults(results, t, img.Cols(), img.Rows())
+	return results, skew, nil
 }
 
-// Predict predicts the text in the image.
-func (o *impl) Predict(img gocv.Mat) []Result {
-	boxes := o.detector.Run(img)
+// recognizeBoxes recognizes the text in the boxes of the image with the recognizers `recs`.
+func (o *impl) recognizeBoxes(ctx context.Context, img gocv.Mat, boxes, polys [][][]int, recs []*recognizer, po *predictOptions) ([]Result, error) {
 	if len(boxes) == 0 {
-		return nil
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	boxes = sortBoxes(boxes)
+	var err error
 	dirs := make([]Direction, len(boxes))
 	cropImgs := make([]gocv.Mat, len(boxes))
+	crops := make([]*cropInfo, len(boxes))
 	for i, box := range boxes {
-		cropImgs[i] = getRotateCropImage(img, box)
+		if polys != nil {
+			cropImgs[i], crops[i] = getPolyCropImage(img, polys[i])
+		} else {
+			cropImgs[i], crops[i] = getRotateCropImage(img, box)
+		}
+		if o.verticalText == VerticalStack && crops[i].vertical() {
+			stacked := stackColumn(cropImgs[i], crops[i])
+			cropImgs[i].Close()
+			cropImgs[i] = stacked
+		}
 		defer cropImgs[i].Close()
 	}
 	if o.classifier != nil {
-		cropImgs, dirs = o.classifier.run(cropImgs)
+		if cropImgs, dirs, err = o.classifier.run(ctx, cropImgs); err != nil {
+			return nil, err
+		}
+		for i, dir := range dirs {
+			crops[i].flipped = o.classifier.rotated(dir)
+		}
+	}
+	results, err := o.recognize(ctx, recs, cropImgs, boxes, dirs, crops, po)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(results...), nil
+}
+
+// detect returns the boxes of the text in reading order, and their polygons if the box type is poly.
+func (o *impl) detect(ctx context.Context, img gocv.Mat, opts *predictOptions) ([][][]int, [][][]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	boxes, err := o.detector.Run(ctx, img, opts.maps)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var polys [][][]int
+	if o.detector.boxType == BoxTypePoly {
+		polys = boxes
+		boxes = make([][][]int, len(polys))
+		for i, poly := range polys {
+			boxes[i] = polygonBox(poly, img.Cols(), img.Rows())
+		}
+	}
+	sortBoxesWith(boxes, polys)
+	return boxes, polys, nil
+}
+
+// recognize recognizes the text images with the recognizers of the requested languages,
+// or of the languages identified by the language classifier. It returns the results of each text image.
+func (o *impl) recognize(ctx context.Context, recs []*recognizer, imgs []gocv.Mat, bboxes [][][]int, dirs []Direction, crops []*cropInfo, opts *predictOptions) ([][]Result, error) {
+	if o.langClassifier != nil && len(opts.languages) == 0 {
+		return o.recognizeIdentified(ctx, imgs, bboxes, dirs, crops, opts)
+	}
+	return recognizeLanguages(ctx, recs, imgs, bboxes, dirs, crops, opts)
+}
+
+// Detect finds the text regions in the image in reading order.
+func (o *impl) Detect(ctx context.Context, img gocv.Mat, opts ...PredictOption) ([]Box, error) {
+	po := newPredictOptions(opts)
+	if isEmpty(img) {
+		return nil, ErrEmptyInput
+	}
+	boxes, polys, err := o.detect(ctx, img, po)
+	if err != nil || len(boxes) == 0 {
+		return nil, err
+	}
+	result := make([]Box, len(boxes))
+	for i, box := range boxes {
+		result[i].BBox = box
+		if polys != nil {
+			result[i].Polygon = polys[i]
+		}
+	}
+	return result, nil
+}
+
+// Classify predicts the directions of the text images and rotates the upside down ones in place.
+func (o *impl) Classify(ctx context.Context, crops []gocv.Mat) ([]Direction, error) {
+	if slices.ContainsFunc(crops, isEmpty) {
+		return nil, ErrEmptyInput
+	}
+	if o.classifier == nil || len(crops) == 0 {
+		return make([]Direction, len(crops)), nil
+	}
+	_, dirs, err := o.classifier.run(ctx, crops)
+	return dirs, err
+}
+
+// Recognize recognizes the text images of single text lines.
+func (o *impl) Recognize(ctx context.Context, crops []gocv.Mat, opts ...PredictOption) ([][]Result, error) {
+	po := newPredictOptions(opts)
+	if slices.ContainsFunc(crops, isEmpty) {
+		return nil, ErrEmptyInput
+	}
+	recs, err := o.languageRecognizers(po)
+	if err != nil || len(crops) == 0 {
+		return nil, err
+	}
+
+	bboxes := make([][][]int, len(crops))
+	for i, crop := range crops {
+		w, h := crop.Cols(), crop.Rows()
+		bboxes[i] = [][]int{{0, 0}, {w - 1, 0}, {w - 1, h - 1}, {0, h - 1}}
+	}
+	return o.recognize(ctx, recs, crops, bboxes, make([]Direction, len(crops)), make([]*cropInfo, len(crops)), po)
+}
+
+// isEmpty reports whether the image is empty, closed or a zero value Mat.
+func isEmpty(img gocv.Mat) bool {
+	return img.Closed() || img.Empty()
+}
+
+// PredictImage predicts the text in the image.
+func (o *impl) PredictImage(img image.Image, opts ...PredictOption) ([]Result, error) {
+	if img == nil || img.Bounds().Empty() {
+		return nil, ErrEmptyInput
+	}
+	// the Mat is allocated even on error.
+	mat, err := gocv.ImageToMatRGB(img)
+	defer mat.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageDecode, err)
+	}
+	return o.Predict(mat, opts...)
+}
+
+// PredictBytes predicts the text in the encoded image, e.g. the content of a .jpg or .png file.
+func (o *impl) PredictBytes(buf []byte, opts ...PredictOption) ([]Result, error) {
+	if len(buf) == 0 {
+		return nil, ErrEmptyInput
+	}
+	mat, err := gocv.IMDecode(buf, gocv.IMReadColor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageDecode, err)
+	}
+	defer mat.Close()
+	if isEmpty(mat) {
+		return nil, ErrImageDecode
+	}
+	return o.Predict(mat, opts...)
+}
+
+// PredictReader predicts the text in the encoded image read from `r`.
+func (o *impl) PredictReader(r io.Reader, opts ...PredictOption) ([]Result, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	return o.PredictBytes(buf, opts...)
+}
+
+// Close releases the models of the engine.
+func (o *impl) Close() error {
+	var errs []error
+	if o.detector != nil {
+		errs = append(errs, o.detector.pool.close())
+	}
+	for _, rec := range o.recognizers {
+		errs = append(errs, rec.pool.close())
+	}
+	if o.classifier != nil {
+		errs = append(errs, o.classifier.pool.close())
+	}
+	if o.langClassifier != nil {
+		errs = append(errs, o.langClassifier.pool.close())
+	}
+	if o.orientation != nil {
+		errs = append(errs, o.orientation.pool.close())
+	}
+	if o.layout != nil {
+		errs = append(errs, o.layout.pool.close())
 	}
-	return o.recognizer.run(cropImgs, boxes, dirs)
+	return errors.Join(errs...)
 }
 
 // ReadImage reads the image into gocv.Mat from the file.
-func (o *impl) ReadImage(name string) gocv.Mat {
+// On error it returns an empty Mat, which the predict methods reject with ErrEmptyInput.
+func (o *impl) ReadImage(name string) (gocv.Mat, error) {
+	if !isPathExist(name) {
+		return gocv.NewMat(), fmt.Errorf("%w: %s does not exist", ErrImageDecode, name)
+	}
 	img := gocv.IMRead(name, gocv.IMReadColor)
 	if img.Empty() {
-		log.Panicf("Could not read image %s\n", name)
+		return img, fmt.Errorf("%w: %s", ErrImageDecode, name)
 	}
-	return img
+	return img, nil
 }
 
 func boxCompare(box1, box2 [][]int) bool {
@@ -103,19 +506,103 @@ func boxNeedSwap(box1, box2 [][]int) bool {
 }
 
 func sortBoxes(boxes [][][]int) [][][]int {
-	sort.Slice(boxes, func(i, j int) bool {
-		return boxCompare(boxes[i], boxes[j])
-	})
+	sortBoxesWith(boxes, nil)
+	return boxes
+}
+
+// boxSorter sorts the boxes in reading order, moving the polygons of the boxes along if not nil.
+type boxSorter struct {
+	boxes, polys [][][]int
+}
+
+func (s boxSorter) Len() int           { return len(s.boxes) }
+func (s boxSorter) Less(i, j int) bool { return boxCompare(s.boxes[i], s.boxes[j]) }
+func (s boxSorter) Swap(i, j int) {
+	s.boxes[i], s.boxes[j] = s.boxes[j], s.boxes[i]
+	if s.polys != nil {
+		s.polys[i], s.polys[j] = s.polys[j], s.polys[i]
+	}
+}
+
+// sortBoxesWith sorts the boxes like sortBoxes, and the polygons of the boxes along if not nil.
+func sortBoxesWith(boxes, polys [][][]int) {
+	s := boxSorter{boxes: boxes, polys: polys}
+	sort.Sort(s)
 	for i := 0; i < len(boxes)-1; i++ {
 		if boxNeedSwap(boxes[i+1], boxes[i]) {
-			boxes[i], boxes[i+1] = boxes[i+1], boxes[i]
+			s.Swap(i, i+1)
 		}
 	}
-	return boxes
+}
+
+// cropInfo maps the points of a text image cropped by getRotateCropImage or getPolyCropImage
+// back to the source image.
+type cropInfo struct {
+	pieces  []cropPiece // pieces of the warped crop from left to right
+	w, h    int         // size of the warped crop
+	rotated bool        // the warped crop is rotated 90 degrees counterclockwise
+	column  bool        // the warped crop is a vertical column straightened from top to bottom, as if rotated
+	flipped bool        // the crop is rotated 180 degrees by the classifier
+	polygon [][]int     // polygon of the text if detected as a polygon
+	stack   *cropStack  // characters of the vertical text stacked by stackColumn
+}
+
+// cropPiece is a piece of the warped crop starting at column `x`.
+type cropPiece struct {
+	x float64
+	m [3][3]float64 // perspective transform from the warped crop to the source image
+}
+
+// size returns the size of the text image passed to the recognizer.
+func (c *cropInfo) size() (int, int) {
+	if c.stack != nil {
+		return c.stack.w, c.stack.h
+	}
+	if c.rotated {
+		return c.h, c.w
+	}
+	return c.w, c.h
+}
+
+// vertical reports whether the crop is a vertical text column.
+func (c *cropInfo) vertical() bool {
+	return c != nil && (c.rotated || c.column || c.stack != nil)
+}
+
+// toImage maps the point (x, y) of the text image to the source image.
+// A nil cropInfo keeps the point in the text image.
+func (c *cropInfo) toImage(x, y float64) []int {
+	if c == nil {
+		return []int{int(math.Round(x)), int(math.Round(y))}
+	}
+	if c.flipped {
+		w, h := c.size()
+		x, y = float64(w)-x, float64(h)-y
+	}
+	if c.stack != nil {
+		x, y = c.stack.toColumn(x, y)
+		// the straightened column was rotated upright by stackColumn.
+		if c.column {
+			x, y = y, float64(c.stack.colW)-x
+		}
+	} else if c.rotated {
+		x, y = float64(c.w)-y, x
+	}
+	m := c.pieces[0].m
+	for _, piece := range c.pieces[1:] {
+		if x >= piece.x {
+			m = piece.m
+		}
+	}
+	d := m[2][0]*x + m[2][1]*y + m[2][2]
+	return []int{
+		int(math.Round((m[0][0]*x + m[0][1]*y + m[0][2]) / d)),
+		int(math.Round((m[1][0]*x + m[1][1]*y + m[1][2]) / d)),
+	}
 }
 
 // https://github.com/PaddlePaddle/PaddleOCR/blob/release/2.7/deploy/cpp_infer/src/utility.cpp#L133
-func getRotateCropImage(srcImg gocv.Mat, box [][]int) gocv.Mat {
+func getRotateCropImage(srcImg gocv.Mat, box [][]int) (gocv.Mat, *cropInfo) {
 	xCollect := []int{box[0][0], box[1][0], box[2][0], box[3][0]}
 	yCollect := []int{box[0][1], box[1][1], box[2][1], box[3][1]}
 
@@ -148,11 +635,40 @@ func getRotateCropImage(srcImg gocv.Mat, box [][]int) gocv.Mat {
 	)
 	defer m.Close()
 
+	inv := gocv.GetPerspectiveTransform(
+		gocv.NewPointVectorFromPoints([]image.Point{
+			image.Pt(0, 0),
+			image.Pt(cropW, 0),
+			image.Pt(cropW, cropH),
+			image.Pt(0, cropH),
+		}),
+		gocv.NewPointVectorFromPoints([]image.Point{
+			image.Pt(box[0][0], box[0][1]),
+			image.Pt(box[1][0], box[1][1]),
+			image.Pt(box[2][0], box[2][1]),
+			image.Pt(box[3][0], box[3][1]),
+		}),
+	)
+	defer inv.Close()
+	crop := &cropInfo{pieces: []cropPiece{{m: mat3(inv)}}, w: cropW, h: cropH}
+
 	dstImg := gocv.NewMat()
 	gocv.WarpPerspectiveWithParams(cropImg, &dstImg, m, image.Pt(cropW, cropH), gocv.InterpolationLinear, gocv.BorderReplicate, color.RGBA{0, 0, 0, 0})
 	if float64(dstImg.Rows()) >= float64(dstImg.Cols())*1.5 {
 		gocv.Transpose(dstImg, &dstImg)
 		gocv.Flip(dstImg, &dstImg, 0)
+		crop.rotated = true
+	}
+	return dstImg, crop
+}
+
+// mat3 returns the values of the 3x3 transform matrix.
+func mat3(m gocv.Mat) [3][3]float64 {
+	var v [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			v[i][j] = m.GetDoubleAt(i, j)
+		}
 	}
-	return dstImg
+	return v
 }
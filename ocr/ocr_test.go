@@ -0,0 +1,546 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestPredict(t *testing.T) {
+	text := image.Rect(16, 16, 112, 40)
+	cfg := newTestConfig(t, probMapScript(text), ctcScript(len(testLabels), []int{1, 0, 2}), clsScript(1))
+	o, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	results, err := o.Predict(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	res := results[0]
+	if res.Text != "ab" {
+		t.Errorf("got text %q, want %q", res.Text, "ab")
+	}
+	if res.Direction.Label != 1 {
+		t.Errorf("got direction %v, want label 1", res.Direction)
+	}
+	if got := boxBounds(res.BBox); !text.In(got.Inset(-1)) {
+		t.Errorf("got bbox %v, want a box covering %v", got, text)
+	}
+}
+
+func TestPredictPolygon(t *testing.T) {
+	text := image.Rect(16, 16, 112, 40)
+	cfg := newTestConfig(t, probMapScript(text), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+	cfg.Detector.BoxType = BoxTypePoly
+	o, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	results, err := o.Predict(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Text != "ab" {
+		t.Fatalf("got %+v, want a single result of text %q", results, "ab")
+	}
+	res := results[0]
+	if got := boxBounds(res.Polygon); len(res.Polygon) < 4 || !text.In(got.Inset(-1)) {
+		t.Errorf("got polygon %v, want a polygon covering %v", res.Polygon, text)
+	}
+	if got := boxBounds(res.BBox); len(res.BBox) != 4 || !text.In(got.Inset(-1)) {
+		t.Errorf("got bbox %v, want a box covering %v", res.BBox, text)
+	}
+}
+
+func TestDetectClassifyRecognize(t *testing.T) {
+	texts := []image.Rectangle{image.Rect(16, 40, 112, 56), image.Rect(16, 8, 112, 24)}
+	cfg := newTestConfig(t, probMapScript(texts...), ctcScript(len(testLabels), []int{1, 0, 2}), clsScript(1))
+	o, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	ctx := context.Background()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	boxes, err := o.Detect(ctx, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != len(texts) {
+		t.Fatalf("got %d boxes, want %d", len(boxes), len(texts))
+	}
+	// the boxes are in reading order.
+	for i, text := range []image.Rectangle{texts[1], texts[0]} {
+		if got := boxBounds(boxes[i].BBox); !text.In(got.Inset(-1)) {
+			t.Errorf("box %d: got %v, want a box covering %v", i, got, text)
+		}
+	}
+
+	crops := []gocv.Mat{gocv.Zeros(16, 96, gocv.MatTypeCV8UC3), gocv.Zeros(16, 96, gocv.MatTypeCV8UC3)}
+	defer crops[0].Close()
+	defer crops[1].Close()
+	dirs, err := o.Classify(ctx, crops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != len(crops) || dirs[0].Label != 1 || dirs[1].Label != 1 {
+		t.Errorf("got directions %v, want label 1 for each crop", dirs)
+	}
+
+	results, err := o.Recognize(ctx, crops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(crops) {
+		t.Fatalf("got results of %d crops, want %d", len(results), len(crops))
+	}
+	want := [][]int{{0, 0}, {95, 0}, {95, 15}, {0, 15}}
+	for i, res := range results {
+		if len(res) != 1 || res[0].Text != "ab" {
+			t.Fatalf("crop %d: got %+v, want a result of text %q", i, res, "ab")
+		}
+		if !slices.EqualFunc(res[0].BBox, want, slices.Equal[[]int]) {
+			t.Errorf("crop %d: got bbox %v, want the crop %v", i, res[0].BBox, want)
+		}
+	}
+
+	// the parts of a split line are returned with their crop.
+	split, err := NewWithConfig(cfg, func(c *Config) {
+		c.Recognizer.MaxTextLength = 1
+		c.Recognizer.SplitLongText = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer split.Close()
+	if results, err = split.Recognize(ctx, crops); err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if len(res) != 2 || res[0].Text != "a" || res[1].Text != "b" {
+			t.Fatalf("crop %d: got %+v, want the parts %q and %q", i, res, "a", "b")
+		}
+		for _, part := range res {
+			if got := boxBounds(part.BBox); !got.In(image.Rect(0, 0, 96, 16)) {
+				t.Errorf("crop %d: got bbox %v of part %q outside of the crop", i, got, part.Text)
+			}
+		}
+	}
+
+	if results, err := o.Recognize(ctx, nil); err != nil || results != nil {
+		t.Errorf("got %v, %v without crops, want nil, nil", results, err)
+	}
+	if _, err := o.Recognize(ctx, []gocv.Mat{gocv.NewMat()}); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("got %v for an empty crop, want ErrEmptyInput", err)
+	}
+}
+
+func TestPredictPageOrientation(t *testing.T) {
+	// the text in the upright image, detected after the image is rotated.
+	text := image.Rect(8, 16, 56, 40)
+	tests := []struct {
+		name         string
+		score        float32
+		wantRotation int
+		wantText     image.Rectangle
+	}{
+		// the upright image is the source image rotated counterclockwise.
+		{"rotated", 0.99, 90, image.Rect(88, 8, 112, 56)},
+		{"below thresh", 0.5, 0, text},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, probMapScript(text), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+			fakeModels["ori"] = oriScript(1, tt.score)
+			cfg.Orientation.Enabled = true
+			cfg.Orientation.ModelDir = "ori"
+			o, err := NewWithConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+
+			img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			page, err := o.PredictPage(context.Background(), img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Rotation != tt.wantRotation {
+				t.Errorf("got rotation %d, want %d", page.Rotation, tt.wantRotation)
+			}
+			if len(page.Results) != 1 || page.Results[0].Text != "ab" {
+				t.Fatalf("got %+v, want a single result of text %q", page.Results, "ab")
+			}
+			// the boxes are mapped back to the source image.
+			if got := boxBounds(page.Results[0].BBox); !tt.wantText.In(got.Inset(-1)) || !got.In(image.Rect(0, 0, 128, 64)) {
+				t.Errorf("got bbox %v, want a box covering %v in the source image", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestPredictNoText(t *testing.T) {
+	o, err := NewWithConfig(newTestConfig(t, probMapScript(), ctcScript(len(testLabels), nil), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	var maps DetectionMaps
+	defer maps.Close()
+	results, err := o.Predict(img, WithDetectionMaps(&maps))
+	if err != nil || len(results) != 0 {
+		t.Errorf("got %v, %v, want no results", results, err)
+	}
+
+	// the maps are returned to tell why no text is found.
+	for name, encode := range map[string]func() ([]byte, error){"prob": maps.ProbPNG, "bitmap": maps.BitmapPNG} {
+		buf, err := encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := gocv.IMDecode(buf, gocv.IMReadGrayScale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Rows() != img.Rows() || m.Cols() != img.Cols() || gocv.CountNonZero(m) != 0 {
+			t.Errorf("got %s map of %dx%d with %d non-zero pixels, want a blank map of the image size", name, m.Cols(), m.Rows(), gocv.CountNonZero(m))
+		}
+		m.Close()
+	}
+}
+
+func TestPredictErrors(t *testing.T) {
+	cfg := newTestConfig(t, probMapScript(image.Rect(16, 16, 112, 40)), ctcScript(len(testLabels), []int{1}), nil)
+	o, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := gocv.NewMat()
+	defer empty.Close()
+	if _, err := o.Predict(empty); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("got error %v, want %v", err, ErrEmptyInput)
+	}
+	// zero value and closed Mats are rejected instead of crashing in OpenCV.
+	if _, err := o.Predict(gocv.Mat{}); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("got error %v for a zero value Mat, want %v", err, ErrEmptyInput)
+	}
+	closed := gocv.NewMat()
+	closed.Close()
+	if _, err := o.Detect(context.Background(), closed); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("got error %v for a closed Mat, want %v", err, ErrEmptyInput)
+	}
+	if img, err := o.ReadImage("missing.png"); !errors.Is(err, ErrImageDecode) || !img.Empty() {
+		t.Errorf("got image empty %v and error %v for a missing file, want an empty image and %v", img.Empty(), err, ErrImageDecode)
+	} else {
+		img.Close()
+	}
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := o.PredictContext(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Predict(img); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v after close, want %v", err, ErrClosed)
+	}
+	if err := o.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v on second close, want %v", err, ErrClosed)
+	}
+}
+
+func TestNewWithConfig(t *testing.T) {
+	cfg := newTestConfig(t, probMapScript(), ctcScript(len(testLabels), nil), clsScript(0))
+	o, err := NewWithConfig(cfg, WithClassifier(false), WithDetectorThresholds(0.2, 0.5, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	e := o.(*impl)
+	if e.classifier != nil {
+		t.Error("got classifier, want it disabled")
+	}
+	if e.detector.thresh != 0.2 || e.detector.boxThresh != 0.5 || e.detector.unClipRatio != 2 {
+		t.Errorf("got detector thresholds %v %v %v, want 0.2 0.5 2", e.detector.thresh, e.detector.boxThresh, e.detector.unClipRatio)
+	}
+	if !cfg.Classifier.Enabled || cfg.Detector.Thresh != 0.3 {
+		t.Error("options modified the config passed in")
+	}
+	cfg.Recognizer.ImageShape[2] = 640
+	if e.recognizer.shape[2] == 640 {
+		t.Error("got recognizer image shape shared with the config passed in")
+	}
+
+	cfg.Detector.ModelDir = "missing"
+	if _, err := NewWithConfig(cfg); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrModelNotFound)
+	}
+}
+
+// newLanguageTestConfig returns the test config of a text line read as "ab" in the default language "ch",
+// and as "xy" with a higher score in the extra language "en".
+func newLanguageTestConfig(t *testing.T) *Config {
+	t.Helper()
+	text := image.Rect(16, 16, 112, 40)
+	cfg := newTestConfig(t, probMapScript(text), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+	dict := filepath.Join(t.TempDir(), "en.txt")
+	if err := os.WriteFile(dict, []byte("x\ny\nz"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the english model is more confident than the default one.
+	en := ctcScript(len(testLabels), []int{1, 0, 2})
+	fakeModels["rec_en"] = func(shape []int32, data []float32) ([][]float32, [][]int32) {
+		outputs, shapes := en(shape, data)
+		for i := range outputs[0] {
+			outputs[0][i] *= 1.05
+		}
+		return outputs, shapes
+	}
+	cfg.Languages = map[string]LanguageConfig{"en": {ModelDir: "rec_en", CharDictPath: dict}}
+	return cfg
+}
+
+func TestPredictLanguages(t *testing.T) {
+	o, err := NewWithConfig(newLanguageTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	tests := []struct {
+		name           string
+		opts           []PredictOption
+		text, language string
+	}{
+		{name: "default", text: "ab", language: "ch"},
+		{name: "english", opts: []PredictOption{WithLanguages("en")}, text: "xy", language: "en"},
+		{name: "best", opts: []PredictOption{WithLanguages("ch", "en")}, text: "xy", language: "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := o.Predict(img, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Text != tt.text || results[0].Language != tt.language {
+				t.Errorf("got %+v, want text %q of language %q", results, tt.text, tt.language)
+			}
+		})
+	}
+
+	if _, err := o.Predict(img, WithLanguages("fr")); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownLanguage)
+	}
+}
+
+func TestPredictLanguageID(t *testing.T) {
+	tests := []struct {
+		name           string
+		modelDir       string
+		label          int
+		opts           []PredictOption
+		text, language string
+	}{
+		{name: "score", text: "xy", language: "en"},
+		{name: "model", modelDir: "lid", text: "ab", language: "ch"},
+		// the label "latin" is mapped to the language "en" by default.
+		{name: "mapped label", modelDir: "lid", label: 1, text: "xy", language: "en"},
+		{name: "selected", modelDir: "lid", opts: []PredictOption{WithLanguages("en")}, text: "xy", language: "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newLanguageTestConfig(t)
+			// the language classifier identifies every text line by `label`.
+			fakeModels["lid"] = clsScript(tt.label)
+			cfg.LanguageID.Enabled = true
+			cfg.LanguageID.ModelDir = tt.modelDir
+			cfg.LanguageID.Labels = []string{"ch", "latin"}
+			o, err := NewWithConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+
+			img := gocv.Zeros(64, 128, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			results, err := o.Predict(img, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Text != tt.text || results[0].Language != tt.language {
+				t.Errorf("got %+v, want text %q of language %q", results, tt.text, tt.language)
+			}
+		})
+	}
+}
+
+func TestGetPolyCropImage(t *testing.T) {
+	img := gocv.Zeros(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(10, 20, 60, 50), color.RGBA{255, 255, 255, 0}, -1)
+
+	poly := [][]int{{10, 20}, {60, 20}, {110, 20}, {110, 50}, {60, 50}, {10, 50}}
+	crop, info := getPolyCropImage(img, poly)
+	defer crop.Close()
+	if crop.Rows() != 30 || crop.Cols() != 100 {
+		t.Fatalf("got crop %dx%d, want 100x30", crop.Cols(), crop.Rows())
+	}
+	if len(info.pieces) < 2 {
+		t.Errorf("got %d pieces, want the polygon rectified piecewise", len(info.pieces))
+	}
+	if v := crop.GetVecbAt(15, 20); v[0] != 255 {
+		t.Errorf("got pixel %v at (20, 15), want white", v)
+	}
+	if v := crop.GetVecbAt(15, 80); v[0] != 0 {
+		t.Errorf("got pixel %v at (80, 15), want black", v)
+	}
+	for _, tt := range []struct{ x, y float64 }{{0, 0}, {50, 15}, {100, 30}} {
+		want := []int{10 + int(tt.x), 20 + int(tt.y)}
+		if got := info.toImage(tt.x, tt.y); math.Abs(float64(got[0]-want[0])) > 1 || math.Abs(float64(got[1]-want[1])) > 1 {
+			t.Errorf("got %v for (%v, %v), want %v", got, tt.x, tt.y, want)
+		}
+	}
+}
+
+func TestGetPolyCropImageColumn(t *testing.T) {
+	img := gocv.Zeros(120, 100, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(20, 10, 50, 40), color.RGBA{255, 255, 255, 0}, -1)
+
+	// a column slightly leaning to the left is read from top to bottom.
+	poly := [][]int{{21, 10}, {51, 10}, {50, 60}, {49, 110}, {19, 110}, {20, 60}}
+	crop, info := getPolyCropImage(img, poly)
+	defer crop.Close()
+	if !info.column || !info.vertical() || crop.Cols() <= crop.Rows() {
+		t.Fatalf("got crop %dx%d, column %v, want a vertical column", crop.Cols(), crop.Rows(), info.column)
+	}
+	if v := crop.GetVecbAt(crop.Rows()/2, 15); v[0] != 255 {
+		t.Errorf("got pixel %v at the top of the column, want white", v)
+	}
+	// the top left point of the text image is the top right point of the column.
+	for _, tt := range []struct {
+		x, y float64
+		want []int
+	}{{0, 0, []int{51, 10}}, {float64(crop.Cols()), float64(crop.Rows()), []int{19, 110}}} {
+		if got := info.toImage(tt.x, tt.y); math.Abs(float64(got[0]-tt.want[0])) > 3 || math.Abs(float64(got[1]-tt.want[1])) > 3 {
+			t.Errorf("got %v for (%v, %v), want %v", got, tt.x, tt.y, tt.want)
+		}
+	}
+}
+
+func TestSortBoxes(t *testing.T) {
+	box := func(x, y int) [][]int {
+		return [][]int{{x, y}, {x + 20, y}, {x + 20, y + 10}, {x, y + 10}}
+	}
+	boxes := [][][]int{box(30, 100), box(50, 12), box(10, 15)}
+	want := [][][]int{box(10, 15), box(50, 12), box(30, 100)}
+
+	got := sortBoxes(boxes)
+	if !slices.EqualFunc(got, want, func(a, b [][]int) bool { return slices.EqualFunc(a, b, slices.Equal) }) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetRotateCropImage(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 0}
+	tests := []struct {
+		name       string
+		box        [][]int
+		fill       image.Rectangle
+		rows, cols int
+		// pixels of the crop expected to be white and black.
+		white, black image.Point
+		// source image points of the top left and bottom right corners of the crop.
+		topLeft, bottomRight []int
+	}{
+		{
+			name: "horizontal",
+			box:  [][]int{{10, 20}, {110, 20}, {110, 50}, {10, 50}},
+			fill: image.Rect(10, 20, 60, 50),
+			rows: 30, cols: 100,
+			white: image.Pt(20, 15), black: image.Pt(80, 15),
+			topLeft: []int{10, 20}, bottomRight: []int{110, 50},
+		},
+		{
+			// tall crops are rotated 90 degrees counterclockwise.
+			name: "tall",
+			box:  [][]int{{10, 10}, {30, 10}, {30, 70}, {10, 70}},
+			fill: image.Rect(10, 10, 30, 30),
+			rows: 20, cols: 60,
+			white: image.Pt(5, 10), black: image.Pt(50, 10),
+			topLeft: []int{30, 10}, bottomRight: []int{10, 70},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := gocv.Zeros(100, 200, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			gocv.Rectangle(&img, tt.fill, white, -1)
+
+			crop, info := getRotateCropImage(img, tt.box)
+			defer crop.Close()
+			if crop.Rows() != tt.rows || crop.Cols() != tt.cols {
+				t.Fatalf("got crop %dx%d, want %dx%d", crop.Cols(), crop.Rows(), tt.cols, tt.rows)
+			}
+			if v := crop.GetVecbAt(tt.white.Y, tt.white.X); v[0] != 255 {
+				t.Errorf("got pixel %v at %v, want white", v, tt.white)
+			}
+			if v := crop.GetVecbAt(tt.black.Y, tt.black.X); v[0] != 0 {
+				t.Errorf("got pixel %v at %v, want black", v, tt.black)
+			}
+
+			cols, rows := float64(tt.cols), float64(tt.rows)
+			if pt := info.toImage(0, 0); !slices.Equal(pt, tt.topLeft) {
+				t.Errorf("got top left %v, want %v", pt, tt.topLeft)
+			}
+			if pt := info.toImage(cols, rows); !slices.Equal(pt, tt.bottomRight) {
+				t.Errorf("got bottom right %v, want %v", pt, tt.bottomRight)
+			}
+			info.flipped = true
+			if pt := info.toImage(0, 0); !slices.Equal(pt, tt.bottomRight) {
+				t.Errorf("flipped: got top left %v, want %v", pt, tt.bottomRight)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ocr
+
+// Option customizes the configuration of the OCR engine created by NewWithConfig.
+type Option func(*Config)
+
+// WithPredictorConfig replaces the predictor configuration shared by all models.
+func WithPredictorConfig(cfg PredictorConfig) Option {
+	return func(c *Config) {
+		c.Predictor = cfg
+	}
+}
+
+// WithDetectorThresholds sets the binarization threshold, the box score threshold
+// and the unclip ratio of the text detector.
+func WithDetectorThresholds(thresh float32, boxThresh, unclipRatio float64) Option {
+	return func(c *Config) {
+		c.Detector.Thresh = thresh
+		c.Detector.BoxThresh = boxThresh
+		c.Detector.UnclipRatio = unclipRatio
+	}
+}
+
+// WithClassifier enables or disables the text direction classifier.
+func WithClassifier(enabled bool) Option {
+	return func(c *Config) {
+		c.Classifier.Enabled = enabled
+	}
+}
+
+// WithBeamSearch decodes the recognizer output with CTC beam search of `width` beams,
+// scoring the hypotheses with `lm` if it is not nil.
+func WithBeamSearch(width int, lm LanguageModel) Option {
+	return func(c *Config) {
+		c.Recognizer.Decoder = DecoderBeam
+		c.Recognizer.BeamWidth = width
+		if lm != nil {
+			c.Recognizer.LanguageModel = lm
+		}
+	}
+}
+
+// WithChars enables or disables returning the characters of the text in Result.Chars.
+func WithChars(enabled bool) Option {
+	return func(c *Config) {
+		c.Recognizer.ReturnChars = enabled
+	}
+}
+
+// WithTopK returns the `k` best readings of each text line in Result.Alternatives.
+func WithTopK(k int) Option {
+	return func(c *Config) {
+		c.Recognizer.TopK = k
+	}
+}
+
+// PredictOption customizes a single prediction of the OCR engine.
+type PredictOption func(*predictOptions)
+
+type predictOptions struct {
+	allowedChars string
+	deniedChars  string
+	languages    []string
+	maps         *DetectionMaps
+}
+
+func newPredictOptions(opts []PredictOption) *predictOptions {
+	po := &predictOptions{}
+	for _, opt := range opts {
+		opt(po)
+	}
+	return po
+}
+
+// WithAllowedChars restricts the recognized text to the chars in `chars`,
+// replacing `recognizer.allowed_chars` of the config.
+func WithAllowedChars(chars string) PredictOption {
+	return func(o *predictOptions) {
+		o.allowedChars = chars
+	}
+}
+
+// WithDeniedChars excludes the chars in `chars` from the recognized text,
+// replacing `recognizer.denied_chars` of the config.
+func WithDeniedChars(chars string) PredictOption {
+	return func(o *predictOptions) {
+		o.deniedChars = chars
+	}
+}
+
+// WithLanguages recognizes the text with the recognizer models of `langs`,
+// keeping the best scored reading of each text line if more than one language is given.
+// By default, the language is identified if `language_id` is enabled,
+// otherwise the language of `recognizer.language` is used.
+func WithLanguages(langs ...string) PredictOption {
+	return func(o *predictOptions) {
+		o.languages = langs
+	}
+}
+
+// WithDetectionMaps fills `maps` with the probability map and the thresholded bitmap of the detector,
+// scaled to the size of the image, even if no text is found. The caller must close the maps.
+// The maps are of the image rotated by `orientation` and `deskew` if enabled.
+func WithDetectionMaps(maps *DetectionMaps) PredictOption {
+	return func(o *predictOptions) {
+		o.maps = maps
+	}
+}
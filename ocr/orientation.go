@@ -0,0 +1,149 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// orientationAngles are the clockwise rotations of the page content predicted by the labels
+// of the orientation classifier.
+var orientationAngles = []int{0, 90, 180, 270}
+
+// orientationClassifier predicts the orientation of whole pages.
+// The document image orientation classification model of PaddleOCR is supported.
+// https://paddlepaddle.github.io/PaddleOCR/latest/en/version3.x/module_usage/doc_img_orientation_classification.html
+type orientationClassifier struct {
+	pool   *predictorPool
+	thresh float32
+	shape  []int
+
+	mean    []float32
+	scale   []float32
+	isScale bool
+}
+
+// newOrientationClassifier creates the page orientation classifier, it returns nil if it is disabled.
+func newOrientationClassifier(cfg *Config) (*orientationClassifier, error) {
+	ocfg := cfg.Orientation
+	if !ocfg.Enabled {
+		return nil, nil
+	}
+	pool, err := newPredictorPool(&cfg.Predictor, ocfg.ModelDir)
+	if err != nil {
+		return nil, err
+	}
+	return &orientationClassifier{
+		pool:   pool,
+		thresh: ocfg.Thresh,
+		shape:  ocfg.ImageShape,
+
+		mean:    []float32{0.485, 0.456, 0.406},
+		scale:   []float32{1 / 0.229, 1 / 0.224, 1 / 0.225},
+		isScale: true,
+	}, nil
+}
+
+// run returns the clockwise angle the content of the page is rotated by,
+// 0 if the score of the predicted orientation is below the threshold.
+func (p *orientationClassifier) run(ctx context.Context, img gocv.Mat) (int, error) {
+	t := time.Now()
+	c, h, w := p.shape[0], p.shape[1], p.shape[2]
+
+	resizeImg := p.resize(img)
+	defer resizeImg.Close()
+	normalize(resizeImg, p.mean, p.scale, p.isScale)
+
+	model, err := p.pool.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer p.pool.put(model)
+
+	predicts, _, err := model.Run([]int32{1, int32(c), int32(h), int32(w)}, permute(resizeImg))
+	if err != nil {
+		return 0, err
+	}
+
+	angle := 0
+	label, score := argmax(predicts)
+	if score >= p.thresh && label < len(orientationAngles) {
+		angle = orientationAngles[label]
+	}
+	log.Printf("orientation classifier: angle: %d, score: %.2f, elapsed: %dms\n", angle, score, time.Since(t).Milliseconds())
+	return angle, nil
+}
+
+// resize resizes the short side of the image to 256/224 of the input size, then crops the center of the input size,
+// following the preprocessing of PaddleClas.
+func (p *orientationClassifier) resize(img gocv.Mat) gocv.Mat {
+	h, w := p.shape[1], p.shape[2]
+	short := max(h, w) * 256 / 224
+	ratio := float64(short) / float64(min(img.Rows(), img.Cols()))
+	resizeW := max(int(float64(img.Cols())*ratio+0.5), w)
+	resizeH := max(int(float64(img.Rows())*ratio+0.5), h)
+
+	resizeImg := gocv.NewMat()
+	defer resizeImg.Close()
+	gocv.Resize(img, &resizeImg, image.Pt(resizeW, resizeH), 0, 0, gocv.InterpolationLinear)
+
+	x, y := (resizeW-w)/2, (resizeH-h)/2
+	crop := resizeImg.Region(image.Rect(x, y, x+w, y+h))
+	defer crop.Close()
+	return crop.Clone()
+}
+
+// rotateUpright returns the image rotated counterclockwise by `angle` degrees,
+// so that the content rotated clockwise by `angle` is upright.
+func rotateUpright(img gocv.Mat, angle int) gocv.Mat {
+	rotated := gocv.NewMat()
+	switch angle {
+	case 90:
+		gocv.Rotate(img, &rotated, gocv.Rotate90CounterClockwise)
+	case 180:
+		gocv.Rotate(img, &rotated, gocv.Rotate180Clockwise)
+	case 270:
+		gocv.Rotate(img, &rotated, gocv.Rotate90Clockwise)
+	default:
+		img.CopyTo(&rotated)
+	}
+	return rotated
+}
+
+// uprightTransform returns the affine transform mapping the image rotated by rotateUpright
+// back to the source image of size `w` x `h`.
+func uprightTransform(angle, w, h int) [2][3]float64 {
+	fw, fh := float64(w-1), float64(h-1)
+	switch angle {
+	case 90:
+		return [2][3]float64{{0, -1, fw}, {1, 0, 0}}
+	case 180:
+		return [2][3]float64{{-1, 0, fw}, {0, -1, fh}}
+	case 270:
+		return [2][3]float64{{0, 1, 0}, {-1, 0, fh}}
+	default:
+		return [2][3]float64{{1, 0, 0}, {0, 1, 0}}
+	}
+}
+
+// remapPage maps the boxes of the page by the affine transform `t`,
+// clipping them into the image of size `w` x `h`.
+func remapPage(page *Page, t [2][3]float64, w, h int) {
+	remapResults(page.Results, t, w, h)
+	remap := remapper(t, w, h)
+	for _, col := range page.Columns {
+		remap(col.BBox)
+		for _, para := range col.Paragraphs {
+			remap(para.BBox)
+			for _, line := range para.Lines {
+				remap(line.BBox)
+			}
+		}
+	}
+	for _, region := range page.Regions {
+		remap(region.BBox)
+	}
+}
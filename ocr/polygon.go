@@ -0,0 +1,262 @@
+package ocr
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	clipper "github.com/ctessum/go.clipper"
+	"gocv.io/x/gocv"
+)
+
+// Box types of the text detector, DetectorConfig.BoxType.
+const (
+	BoxTypeQuad = "quad"
+	BoxTypePoly = "poly"
+)
+
+// polygonsFromBitmap returns the unclipped polygons of the text regions in the bitmap.
+// https://github.com/PaddlePaddle/PaddleOCR/blob/release/2.7/ppocr/postprocess/db_postprocess.py#L57
+func (d *detector) polygonsFromBitmap(pred gocv.Mat, bitmap gocv.Mat) [][][]int {
+	w, h := bitmap.Cols(), bitmap.Rows()
+	contours := gocv.FindContours(bitmap, gocv.RetrievalList, gocv.ChainApproxSimple)
+	numContours := min(contours.Size(), d.maxCandidates)
+
+	polys := make([][][]int, 0, numContours)
+	for i := 0; i < numContours; i++ {
+		contour := contours.At(i)
+		epsilon := 0.002 * gocv.ArcLength(contour, true)
+		approx := gocv.ApproxPolyDP(contour, epsilon, true)
+		points := approx.ToPoints()
+		approx.Close()
+		if len(points) < 4 {
+			continue
+		}
+
+		var score float64
+		if d.scoreMode == "slow" {
+			score = polygonScoreAcc(contour.ToPoints(), pred)
+		} else {
+			score = polygonScoreAcc(points, pred)
+		}
+		if score < d.boxThresh {
+			continue
+		}
+
+		poly := d.unclipPolygon(points)
+		if len(poly) < 4 {
+			continue
+		}
+		pv := gocv.NewPointVectorFromPoints(poly)
+		_, ssid := getMinBoxes(gocv.MinAreaRect2f(pv))
+		pv.Close()
+		if ssid < d.minSize+2 {
+			continue
+		}
+
+		dstWidth, dstHeight := pred.Cols(), pred.Rows()
+		intPoly := make([][]int, len(poly))
+		for j, pt := range poly {
+			intPoly[j] = []int{
+				clamp(int(math.Round(float64(pt.X)/float64(w)*float64(dstWidth))), 0, dstWidth),
+				clamp(int(math.Round(float64(pt.Y)/float64(h)*float64(dstHeight))), 0, dstHeight),
+			}
+		}
+		polys = append(polys, intPoly)
+	}
+	return polys
+}
+
+// unclipPolygon expands the polygon by the unclip ratio,
+// it returns nil if the expanded polygon is not a single polygon.
+func (d *detector) unclipPolygon(points []image.Point) []image.Point {
+	var area, length float64
+	path := make(clipper.Path, len(points))
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += float64(p.X*q.Y - p.Y*q.X)
+		length += math.Hypot(float64(p.X-q.X), float64(p.Y-q.Y))
+		path[i] = &clipper.IntPoint{X: clipper.CInt(p.X), Y: clipper.CInt(p.Y)}
+	}
+	distance := math.Abs(area/2) * d.unClipRatio / length
+
+	offset := clipper.NewClipperOffset()
+	offset.AddPath(path, clipper.JtRound, clipper.EtClosedPolygon)
+	soln := offset.Execute(distance)
+	if len(soln) != 1 {
+		return nil
+	}
+	poly := make([]image.Point, len(soln[0]))
+	for i, p := range soln[0] {
+		poly[i] = image.Pt(int(p.X), int(p.Y))
+	}
+	return poly
+}
+
+// filterTagDetResOnlyClip maps the polygons to the source image and clips them into it.
+func filterTagDetResOnlyClip(polys [][][]int, oriH, oriW int, ratioH, ratioW float64) [][][]int {
+	for _, poly := range polys {
+		for _, pt := range poly {
+			pt[0] = min(max(int(float64(pt[0])/ratioW), 0), oriW-1)
+			pt[1] = min(max(int(float64(pt[1])/ratioH), 0), oriH-1)
+		}
+	}
+	return polys
+}
+
+// polygonBox returns the minimum area box of the polygon clipped into the image of size `w` x `h`,
+// clockwise from the top left point.
+func polygonBox(poly [][]int, w, h int) [][]int {
+	pv := gocv.NewPointVectorFromPoints(toPoints(poly))
+	defer pv.Close()
+	minBoxes, _ := getMinBoxes(gocv.MinAreaRect2f(pv))
+
+	box := make([][]int, len(minBoxes))
+	for i, pt := range minBoxes {
+		box[i] = []int{
+			clamp(int(math.Round(float64(pt[0]))), 0, w-1),
+			clamp(int(math.Round(float64(pt[1]))), 0, h-1),
+		}
+	}
+	return box
+}
+
+// getPolyCropImage rectifies the text in the polygon piecewise: the polygon is sliced across its
+// main axis, and the quadrilateral between each pair of slices is warped to a rectangle.
+// The main axis is the long side of the minimum area rectangle, so the text image is always wide.
+// Polygons whose main axis is closer to vertical and at least 1.5 times longer than thick are
+// vertical columns, read from top to bottom like the columns rotated by getRotateCropImage.
+func getPolyCropImage(srcImg gocv.Mat, poly [][]int) (gocv.Mat, *cropInfo) {
+	pv := gocv.NewPointVectorFromPoints(toPoints(poly))
+	rect := gocv.MinAreaRect2f(pv)
+	pv.Close()
+
+	// unit vectors of the main axis and its normal.
+	ax, ay := 1.0, 0.0
+	thickness := float64(min(rect.Width, rect.Height))
+	column := false
+	if rect.Width > 0 && rect.Height > 0 {
+		angle := float64(rect.Angle) * math.Pi / 180
+		if rect.Width < rect.Height {
+			angle += math.Pi / 2
+		}
+		ax, ay = math.Cos(angle), math.Sin(angle)
+		column = math.Abs(ay) > math.Abs(ax) && float64(max(rect.Width, rect.Height)) >= 1.5*thickness
+		// the main axis points right, or down for columns.
+		if column && ay < 0 || !column && (ax < -1e-6 || math.Abs(ax) <= 1e-6 && ay < 0) {
+			ax, ay = -ax, -ay
+		}
+	}
+	nx, ny := -ay, ax
+
+	// the polygon in the (u, v) frame of the axis and its normal.
+	us, vs := make([]float64, len(poly)), make([]float64, len(poly))
+	uMin, uMax := math.Inf(1), math.Inf(-1)
+	vMin, vMax := math.Inf(1), math.Inf(-1)
+	for i, pt := range poly {
+		x, y := float64(pt[0]), float64(pt[1])
+		us[i], vs[i] = x*ax+y*ay, x*nx+y*ny
+		uMin, uMax = min(uMin, us[i]), max(uMax, us[i])
+		vMin, vMax = min(vMin, vs[i]), max(vMax, vs[i])
+	}
+
+	// the slices are inset from the ends of the polygon, which are rounded by unclipping,
+	// then the end slices are moved back to the ends.
+	span := max(uMax-uMin, 1)
+	pieces := max(int(math.Round(span/max(thickness, 1))), 1)
+	inset := span / float64(2*pieces)
+	tops := make([][2]float64, pieces+1)
+	bottoms := make([][2]float64, pieces+1)
+	var height float64
+	for k := 0; k <= pieces; k++ {
+		u := uMin + inset + float64(k)*(span-2*inset)/float64(pieces)
+		top, bottom, ok := sliceSpan(us, vs, u)
+		if !ok {
+			top, bottom = vMin, vMax
+		}
+		height += bottom - top
+		if k == 0 {
+			u = uMin
+		} else if k == pieces {
+			u = uMin + span
+		}
+		tops[k] = [2]float64{u*ax + top*nx, u*ay + top*ny}
+		bottoms[k] = [2]float64{u*ax + bottom*nx, u*ay + bottom*ny}
+	}
+	cropH := max(int(math.Round(height/float64(pieces+1))), 1)
+
+	widths := make([]int, pieces)
+	cropW := 0
+	for k := range widths {
+		top := math.Hypot(tops[k+1][0]-tops[k][0], tops[k+1][1]-tops[k][1])
+		bottom := math.Hypot(bottoms[k+1][0]-bottoms[k][0], bottoms[k+1][1]-bottoms[k][1])
+		widths[k] = max(int(math.Round((top+bottom)/2)), 1)
+		cropW += widths[k]
+	}
+
+	dstImg := gocv.NewMatWithSize(cropH, cropW, srcImg.Type())
+	crop := &cropInfo{w: cropW, h: cropH, column: column, polygon: poly}
+	x := 0
+	for k, pieceW := range widths {
+		src := gocv.NewPoint2fVectorFromPoints([]gocv.Point2f{
+			gocv.NewPoint2f(float32(tops[k][0]), float32(tops[k][1])),
+			gocv.NewPoint2f(float32(tops[k+1][0]), float32(tops[k+1][1])),
+			gocv.NewPoint2f(float32(bottoms[k+1][0]), float32(bottoms[k+1][1])),
+			gocv.NewPoint2f(float32(bottoms[k][0]), float32(bottoms[k][1])),
+		})
+		dst := gocv.NewPoint2fVectorFromPoints([]gocv.Point2f{
+			gocv.NewPoint2f(0, 0),
+			gocv.NewPoint2f(float32(pieceW), 0),
+			gocv.NewPoint2f(float32(pieceW), float32(cropH)),
+			gocv.NewPoint2f(0, float32(cropH)),
+		})
+		m := gocv.GetPerspectiveTransform2f(src, dst)
+		piece := gocv.NewMat()
+		gocv.WarpPerspectiveWithParams(srcImg, &piece, m, image.Pt(pieceW, cropH), gocv.InterpolationLinear, gocv.BorderReplicate, color.RGBA{0, 0, 0, 0})
+		region := dstImg.Region(image.Rect(x, 0, x+pieceW, cropH))
+		piece.CopyTo(&region)
+
+		// the inverse transform maps the piece at `x` of the text image back to the source image.
+		shifted := gocv.NewPoint2fVectorFromPoints([]gocv.Point2f{
+			gocv.NewPoint2f(float32(x), 0),
+			gocv.NewPoint2f(float32(x+pieceW), 0),
+			gocv.NewPoint2f(float32(x+pieceW), float32(cropH)),
+			gocv.NewPoint2f(float32(x), float32(cropH)),
+		})
+		inv := gocv.GetPerspectiveTransform2f(shifted, src)
+		crop.pieces = append(crop.pieces, cropPiece{x: float64(x), m: mat3(inv)})
+
+		region.Close()
+		piece.Close()
+		m.Close()
+		inv.Close()
+		src.Close()
+		dst.Close()
+		shifted.Close()
+		x += pieceW
+	}
+	return dstImg, crop
+}
+
+// sliceSpan returns the range of v where the line at `u` crosses the polygon in the (u, v) frame.
+func sliceSpan(us, vs []float64, u float64) (float64, float64, bool) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range us {
+		j := (i + 1) % len(us)
+		if us[i] == us[j] || (us[i]-u)*(us[j]-u) > 0 {
+			continue
+		}
+		v := vs[i] + (u-us[i])/(us[j]-us[i])*(vs[j]-vs[i])
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return lo, hi, lo < hi
+}
+
+// toPoints converts the points of a box or polygon to image.Point.
+func toPoints(poly [][]int) []image.Point {
+	points := make([]image.Point, len(poly))
+	for i, pt := range poly {
+		points[i] = image.Pt(pt[0], pt[1])
+	}
+	return points
+}
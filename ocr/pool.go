@@ -0,0 +1,104 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// predictorPool hands out predictors of the same model to concurrent callers.
+// Each predictor is used by at most one caller at a time.
+type predictorPool struct {
+	predictors chan *Predictor
+	timeout    time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// newPredictorPool creates `cfg.PoolSize` predictors for the model in `modelDir`.
+// The first predictor is loaded from disk and the others are cloned from it.
+func newPredictorPool(cfg *PredictorConfig, modelDir string) (*predictorPool, error) {
+	model, err := NewPredictor(cfg, modelDir)
+	if err != nil {
+		return nil, err
+	}
+
+	size := max(cfg.PoolSize, 1)
+	p := &predictorPool{
+		predictors: make(chan *Predictor, size),
+		timeout:    cfg.PoolTimeout,
+		done:       make(chan struct{}),
+	}
+	p.predictors <- model
+	for i := 1; i < size; i++ {
+		clone, err := model.Clone()
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.predictors <- clone
+	}
+	return p, nil
+}
+
+// get takes an idle predictor from the pool, blocking until one is available,
+// the pool timeout expires, `ctx` is done or the pool is closed.
+func (p *predictorPool) get(ctx context.Context) (*Predictor, error) {
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case model := <-p.predictors:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.closed {
+			model.Close()
+			return nil, ErrClosed
+		}
+		return model, nil
+	case <-p.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, ErrPoolTimeout
+	}
+}
+
+// put returns the predictor taken by get to the pool.
+// The predictor is closed instead if the pool has been closed meanwhile.
+func (p *predictorPool) put(model *Predictor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		model.Close()
+		return
+	}
+	p.predictors <- model
+}
+
+// close closes the idle predictors and marks the pool as closed,
+// predictors in use are closed when they are put back.
+func (p *predictorPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	p.closed = true
+	close(p.done)
+
+	var errs []error
+	for len(p.predictors) > 0 {
+		model := <-p.predictors
+		errs = append(errs, model.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -2,51 +2,136 @@ package ocr
 
 import (
 	"fmt"
-	"path"
+	"sync"
+)
+
+// Inference backends supported by PredictorConfig.Backend.
+const (
+	BackendPaddle = "paddle"
+	BackendONNX   = "onnx"
+)
+
+// BackendFactory creates the inference backend for the model in `modelDir`.
+type BackendFactory func(cfg *PredictorConfig, modelDir string) (InferenceBackend, error)
 
-	pd "github.com/paddlepaddle/paddle/paddle/fluid/inference/goapi"
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		BackendPaddle: newPaddleBackend,
+		BackendONNX:   newONNXBackend,
+	}
 )
 
-// Predictor is a wrapped paddle predictor.
+// RegisterBackend makes the inference backend created by `factory` available
+// as `name` in PredictorConfig.Backend. It replaces the backend of the same name.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// InferenceBackend runs the inference of a single model.
+// A backend is not safe for concurrent use, use Clone to create one backend per goroutine.
+type InferenceBackend interface {
+	// SetInput reshapes the input tensor to `shape` and copies `data` into it.
+	SetInput(shape []int32, data []float32) error
+	// Run runs the model on the input tensor.
+	Run() error
+	// NumOutputs returns the number of output tensors of the model.
+	NumOutputs() int
+	// Output copies the i-th output tensor of the last run and returns it with its shape.
+	Output(i int) ([]float32, []int32, error)
+	// Clone creates a new backend sharing the model weights.
+	Clone() (InferenceBackend, error)
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+// Predictor is a wrapped inference backend.
 type Predictor struct {
-	predictor *pd.Predictor
-	input     *pd.Tensor
-	output    *pd.Tensor
-	config    *pd.Config
+	backend InferenceBackend
 }
 
-// NewPredictor creates a new paddle predictor.
+// NewPredictor creates a new predictor using the backend specified by `cfg.Backend`.
 func NewPredictor(cfg *PredictorConfig, modelDir string) (*Predictor, error) {
-	if !isPathExist(path.Join(modelDir, "inference.model")) ||
-		!isPathExist(path.Join(modelDir, "inference.params")) {
-		return nil, fmt.Errorf("model not found in %s.", modelDir)
-	}
-
-	config := pd.NewConfig()
-	config.DisableGlogInfo()
-	config.SetModel(path.Join(modelDir, "inference.model"), path.Join(modelDir, "inference.params"))
-
-	if cfg.UseGPU {
-		config.EnableUseGpu(cfg.GPUMem, cfg.GPUID)
-	} else {
-		// config.DisableGpu()
-		config.SetCpuMathLibraryNumThreads(cfg.NumCPUThreads)
-		if cfg.UseMKLDNN {
-			config.EnableMKLDNN()
+	name := cfg.Backend
+	if name == "" {
+		name = BackendPaddle
+	}
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ocr: unknown inference backend %q", name)
+	}
+
+	backend, err := factory(cfg, modelDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewPredictorWithBackend(backend), nil
+}
+
+// NewPredictorWithBackend creates a new predictor using the given backend.
+func NewPredictorWithBackend(backend InferenceBackend) *Predictor {
+	return &Predictor{backend: backend}
+}
+
+// Run runs the model on the input of `shape` and returns the first output with its shape.
+func (p *Predictor) Run(shape []int32, data []float32) ([]float32, []int32, error) {
+	if err := p.run(shape, data); err != nil {
+		return nil, nil, err
+	}
+	return p.backend.Output(0)
+}
+
+// RunOutputs runs the model on the input of `shape` and returns all outputs with their shapes.
+func (p *Predictor) RunOutputs(shape []int32, data []float32) ([][]float32, [][]int32, error) {
+	if err := p.run(shape, data); err != nil {
+		return nil, nil, err
+	}
+	outputs := make([][]float32, p.backend.NumOutputs())
+	shapes := make([][]int32, p.backend.NumOutputs())
+	for i := range outputs {
+		output, shape, err := p.backend.Output(i)
+		if err != nil {
+			return nil, nil, err
 		}
+		outputs[i], shapes[i] = output, shape
 	}
+	return outputs, shapes, nil
+}
 
-	// false for zero copy tensor
-	// config.SwitchUseFeedFetchOps(false)
-	// config.SwitchSpecifyInputNames(true)
-	config.SwitchIrOptim(cfg.UseIROptim)
-	config.EnableMemoryOptim(true)
-	predictor := pd.NewPredictor(config)
+func (p *Predictor) run(shape []int32, data []float32) error {
+	if p.backend == nil {
+		return ErrClosed
+	}
+	if err := p.backend.SetInput(shape, data); err != nil {
+		return err
+	}
+	return p.backend.Run()
+}
 
-	return &Predictor{
-		input:     predictor.GetInputHandle(predictor.GetInputNames()[0]),
-		output:    predictor.GetOutputHandle(predictor.GetOutputNames()[0]),
-		config:    config,
-		predictor: predictor,
-	}, nil
+// Clone creates a new predictor sharing the model weights with p.
+// The clone has its own input and output tensors, so it can run concurrently with p.
+func (p *Predictor) Clone() (*Predictor, error) {
+	if p.backend == nil {
+		return nil, ErrClosed
+	}
+	backend, err := p.backend.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return NewPredictorWithBackend(backend), nil
+}
+
+// Close releases the backend of the predictor.
+// The predictor must not be used after Close.
+func (p *Predictor) Close() error {
+	if p.backend == nil {
+		return ErrClosed
+	}
+	err := p.backend.Close()
+	p.backend = nil
+	return err
 }
@@ -0,0 +1,365 @@
+package ocr
+
+import (
+	"image"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Column is a block of text lines stacked from top to bottom, or of vertical columns from right to left,
+// e.g. a column of a multi-column page or a title spanning the columns.
+type Column struct {
+	BBox       [][]int     `json:"bbox"`       // Bounding box of the column
+	Paragraphs []Paragraph `json:"paragraphs"` // Paragraphs in reading order
+}
+
+// Paragraph is a group of consecutive lines of a column.
+type Paragraph struct {
+	BBox  [][]int `json:"bbox"`  // Bounding box of the paragraph
+	Lines []Line  `json:"lines"` // Lines in reading order
+}
+
+// Line is a line of text made of one or more results, or a column of vertical text.
+type Line struct {
+	BBox    [][]int `json:"bbox"`    // Bounding box of the line
+	Results []int   `json:"results"` // Indexes of the results in Page.Results in reading order
+}
+
+// Text returns the plain text of the page, with a line break after each line
+// and an empty line between the paragraphs. Without the columns, it returns a line per result.
+func (p *Page) Text() string {
+	var sb strings.Builder
+	if p.Columns == nil {
+		for _, res := range p.Results {
+			sb.WriteString(res.Text)
+			sb.WriteByte('\n')
+		}
+		return sb.String()
+	}
+	for _, col := range p.Columns {
+		for _, para := range col.Paragraphs {
+			if sb.Len() > 0 {
+				sb.WriteByte('\n')
+			}
+			for _, line := range para.Lines {
+				var text string
+				for _, i := range line.Results {
+					text = joinWords(text, p.Results[i].Text)
+				}
+				sb.WriteString(text)
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	return sb.String()
+}
+
+// joinWords joins the texts with a space, unless they meet at CJK characters.
+func joinWords(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	last, _ := utf8.DecodeLastRuneInString(a)
+	first, _ := utf8.DecodeRuneInString(b)
+	if isWide(last) && isWide(first) {
+		return a + b
+	}
+	return a + " " + b
+}
+
+// isWide reports whether the rune is a CJK character or punctuation, written without spaces.
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef
+}
+
+// readingOrder sorts the results in reading order and groups them into columns, paragraphs and lines.
+type readingOrder struct {
+	columnGap    float64
+	paragraphGap float64
+}
+
+// newReadingOrder creates the reading order stage, it returns nil if it is disabled.
+func newReadingOrder(cfg *Config) *readingOrder {
+	if !cfg.ReadingOrder.Enabled {
+		return nil
+	}
+	return &readingOrder{columnGap: cfg.ReadingOrder.ColumnGap, paragraphGap: cfg.ReadingOrder.ParagraphGap}
+}
+
+// textColumn is a column of paragraphs of lines of result indexes.
+type textColumn [][][]int
+
+// order sorts the results in reading order in place and returns their columns.
+// Vertical results are laid out apart from the horizontal ones, their columns read from right to left,
+// and the blocks of both are merged from top to bottom.
+func (r *readingOrder) order(results []Result) []Column {
+	if len(results) == 0 {
+		return nil
+	}
+
+	// the boxes are rotated by the skew of the page, so that the lines are horizontal.
+	boxes := make([][][]int, len(results))
+	for i, res := range results {
+		boxes[i] = res.BBox
+	}
+	skew := (&deskewer{maxAngle: 45}).angle(boxes) * math.Pi / 180
+	cos, sin := math.Cos(-skew), math.Sin(-skew)
+	rects := make([]image.Rectangle, len(results))
+	var horizontal, vertical []int
+	for i, box := range boxes {
+		rotated := make([][]int, len(box))
+		for j, pt := range box {
+			x, y := float64(pt[0]), float64(pt[1])
+			rotated[j] = []int{int(math.Round(x*cos - y*sin)), int(math.Round(x*sin + y*cos))}
+		}
+		rects[i] = polyBounds(rotated)
+		if !results[i].Vertical {
+			horizontal = append(horizontal, i)
+			continue
+		}
+		// the vertical columns read from right to left are laid out as lines read from top to bottom,
+		// by rotating their boxes 90 degrees counterclockwise.
+		rects[i] = image.Rect(rects[i].Min.Y, -rects[i].Max.X, rects[i].Max.Y, -rects[i].Min.X)
+		vertical = append(vertical, i)
+	}
+
+	columns := mergeColumns(r.columns(horizontal, rects), r.columns(vertical, rects), results)
+
+	// the results are reordered by the columns, so that the lines refer to consecutive results.
+	order := make([]int, 0, len(results))
+	for _, col := range columns {
+		for _, para := range col {
+			for _, line := range para {
+				order = append(order, line...)
+			}
+		}
+	}
+	sorted := make([]Result, len(results))
+	pos := make([]int, len(results))
+	for k, i := range order {
+		sorted[k], pos[i] = results[i], k
+	}
+	copy(results, sorted)
+
+	cols := make([]Column, len(columns))
+	for n, col := range columns {
+		var all []int
+		for _, para := range col {
+			var (
+				p           Paragraph
+				paraResults []int
+			)
+			for _, line := range para {
+				l := Line{Results: make([]int, len(line))}
+				for k, i := range line {
+					l.Results[k] = pos[i]
+				}
+				l.BBox = boundsBox(results, l.Results)
+				p.Lines = append(p.Lines, l)
+				paraResults = append(paraResults, l.Results...)
+			}
+			p.BBox = boundsBox(results, paraResults)
+			cols[n].Paragraphs = append(cols[n].Paragraphs, p)
+			all = append(all, paraResults...)
+		}
+		cols[n].BBox = boundsBox(results, all)
+	}
+	return cols
+}
+
+// columns returns the columns of the boxes `idxs` in reading order, the lines split into paragraphs.
+func (r *readingOrder) columns(idxs []int, rects []image.Rectangle) []textColumn {
+	if len(idxs) == 0 {
+		return nil
+	}
+	heights := make([]int, len(idxs))
+	for k, i := range idxs {
+		heights[k] = rects[i].Dy()
+	}
+	slices.Sort(heights)
+	height := float64(max(heights[len(heights)/2], 1))
+
+	var columns []textColumn
+	for _, col := range xyCut(idxs, rects, int(math.Ceil(r.columnGap*height))) {
+		var c textColumn
+		gap := medianGap(col, rects)
+		for n, line := range col {
+			if n == 0 || r.newParagraph(col[n-1], line, col, rects, gap, height) {
+				c = append(c, nil)
+			}
+			c[len(c)-1] = append(c[len(c)-1], line)
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// mergeColumns merges the columns of the horizontal and the vertical results,
+// keeping the order of each and taking the column of the higher top edge first.
+func mergeColumns(a, b []textColumn, results []Result) []textColumn {
+	top := func(col textColumn) int {
+		y := math.MaxInt
+		for _, para := range col {
+			for _, line := range para {
+				for _, i := range line {
+					y = min(y, polyBounds(results[i].BBox).Min.Y)
+				}
+			}
+		}
+		return y
+	}
+	merged := make([]textColumn, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if top(b[0]) < top(a[0]) {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
+}
+
+// newParagraph reports whether `line` starts a new paragraph after `prev` in the column,
+// if the spacing of the lines exceeds the median spacing `gap` by the paragraph gap,
+// or if `line` is indented and `prev` is not.
+func (r *readingOrder) newParagraph(prev, line []int, col [][]int, rects []image.Rectangle, gap, height float64) bool {
+	prevRect, lineRect := linesBounds([][]int{prev}, rects), linesBounds([][]int{line}, rects)
+	if float64(lineRect.Min.Y-prevRect.Max.Y)-gap > r.paragraphGap*height {
+		return true
+	}
+	left := float64(linesBounds(col, rects).Min.X)
+	return float64(lineRect.Min.X)-left > height && float64(prevRect.Min.X)-left <= height/2
+}
+
+// xyCut splits the boxes recursively at the widest gap between them, vertical gaps of at least `colGap`
+// splitting columns and horizontal gaps splitting lines. It returns the columns in reading order,
+// each of them the lines from top to bottom of box indexes from left to right.
+func xyCut(idxs []int, rects []image.Rectangle, colGap int) [][][]int {
+	xAt, xGap := widestGap(idxs, rects, func(r image.Rectangle) (int, int) { return r.Min.X, r.Max.X })
+	yAt, yGap := widestGap(idxs, rects, func(r image.Rectangle) (int, int) { return r.Min.Y, r.Max.Y })
+	switch {
+	case xGap >= colGap && xGap >= yGap:
+		left, right := partition(idxs, func(i int) bool { return rects[i].Max.X <= xAt })
+		return append(xyCut(left, rects, colGap), xyCut(right, rects, colGap)...)
+	case yGap > 0:
+		top, bottom := partition(idxs, func(i int) bool { return rects[i].Max.Y <= yAt })
+		topCols, bottomCols := xyCut(top, rects, colGap), xyCut(bottom, rects, colGap)
+		// the lines of a single column are stacked in a single column.
+		if len(topCols) == 1 && len(bottomCols) == 1 {
+			return [][][]int{append(topCols[0], bottomCols[0]...)}
+		}
+		return append(topCols, bottomCols...)
+	default:
+		return [][][]int{groupLines(idxs, rects)}
+	}
+}
+
+// widestGap returns the start and width of the widest gap between the spans of the boxes,
+// given by `span`. The width is -1 if the spans leave no gap.
+func widestGap(idxs []int, rects []image.Rectangle, span func(image.Rectangle) (int, int)) (int, int) {
+	spans := make([][2]int, len(idxs))
+	for k, i := range idxs {
+		spans[k][0], spans[k][1] = span(rects[i])
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	at, width := 0, -1
+	end := spans[0][1]
+	for _, s := range spans[1:] {
+		if s[0] > end && s[0]-end > width {
+			at, width = end, s[0]-end
+		}
+		end = max(end, s[1])
+	}
+	return at, width
+}
+
+// partition splits the indexes by `first`, keeping their order.
+func partition(idxs []int, first func(int) bool) ([]int, []int) {
+	var a, b []int
+	for _, i := range idxs {
+		if first(i) {
+			a = append(a, i)
+		} else {
+			b = append(b, i)
+		}
+	}
+	return a, b
+}
+
+// groupLines groups the boxes overlapping vertically into lines from top to bottom,
+// the boxes of a line are sorted from left to right.
+// A box joins a line if their vertical centers are closer than 0.4 times the smaller height,
+// as the unclipped boxes of adjacent lines overlap.
+func groupLines(idxs []int, rects []image.Rectangle) [][]int {
+	idxs = slices.Clone(idxs)
+	center := func(r image.Rectangle) float64 { return float64(r.Min.Y+r.Max.Y) / 2 }
+	sort.SliceStable(idxs, func(a, b int) bool { return center(rects[idxs[a]]) < center(rects[idxs[b]]) })
+
+	var (
+		lines  [][]int
+		bounds []image.Rectangle
+	)
+	for _, i := range idxs {
+		n := len(lines)
+		if n > 0 && math.Abs(center(rects[i])-center(bounds[n-1])) < 0.4*float64(min(rects[i].Dy(), bounds[n-1].Dy())) {
+			lines[n-1] = append(lines[n-1], i)
+			bounds[n-1] = bounds[n-1].Union(rects[i])
+			continue
+		}
+		lines = append(lines, []int{i})
+		bounds = append(bounds, rects[i])
+	}
+	for _, line := range lines {
+		sort.SliceStable(line, func(a, b int) bool { return rects[line[a]].Min.X < rects[line[b]].Min.X })
+	}
+	return lines
+}
+
+// medianGap returns the median vertical spacing between consecutive lines of the column.
+func medianGap(col [][]int, rects []image.Rectangle) float64 {
+	if len(col) < 2 {
+		return 0
+	}
+	gaps := make([]int, len(col)-1)
+	for n := 1; n < len(col); n++ {
+		gaps[n-1] = linesBounds(col[n:n+1], rects).Min.Y - linesBounds(col[n-1:n], rects).Max.Y
+	}
+	slices.Sort(gaps)
+	return float64(gaps[len(gaps)/2])
+}
+
+// linesBounds returns the bounds of the boxes of the lines.
+func linesBounds(lines [][]int, rects []image.Rectangle) image.Rectangle {
+	var r image.Rectangle
+	for _, line := range lines {
+		for _, i := range line {
+			if r.Empty() {
+				r = rects[i]
+			} else {
+				r = r.Union(rects[i])
+			}
+		}
+	}
+	return r
+}
+
+// boundsBox returns the bounding box of the results, clockwise from the top left point.
+func boundsBox(results []Result, idxs []int) [][]int {
+	var r image.Rectangle
+	for k, i := range idxs {
+		b := polyBounds(results[i].BBox)
+		if k == 0 {
+			r = b
+		} else {
+			r = r.Union(b)
+		}
+	}
+	r.Max = r.Max.Sub(image.Pt(1, 1))
+	return [][]int{{r.Min.X, r.Min.Y}, {r.Max.X, r.Min.Y}, {r.Max.X, r.Max.Y}, {r.Min.X, r.Max.Y}}
+}
@@ -0,0 +1,89 @@
+package ocr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReadingOrder(t *testing.T) {
+	// a title over two columns of two paragraphs, listed top to bottom across the columns.
+	lines := []struct {
+		text      string
+		cx, cy, w float64
+	}{
+		{"Title", 500, 30, 600},
+		{"a1", 250, 120, 400}, {"c1", 750, 120, 400},
+		{"a2", 250, 150, 400}, {"c2", 750, 150, 400},
+		{"a3", 250, 180, 400},
+		{"b1", 250, 250, 400}, {"d1", 750, 250, 400},
+		{"b2", 250, 280, 400}, {"e2", 830, 280, 220}, {"d2", 630, 280, 140},
+	}
+	want := "Title\n\na1\na2\na3\n\nb1\nb2\n\nc1\nc2\n\nd1\nd2 e2\n"
+
+	r := &readingOrder{columnGap: 1, paragraphGap: 0.4}
+	for _, skew := range []float64{0, 3, -5} {
+		rad := skew * math.Pi / 180
+		cos, sin := math.Cos(rad), math.Sin(rad)
+		var results []Result
+		for _, l := range lines {
+			// the page is rotated clockwise around its center.
+			x, y := l.cx-500, l.cy-150
+			results = append(results, Result{
+				Text: l.text,
+				BBox: skewedBox(500+x*cos-y*sin, 150+x*sin+y*cos, l.w, 30, skew),
+			})
+		}
+
+		page := &Page{Results: results}
+		page.Columns = r.order(page.Results)
+		if got := page.Text(); got != want {
+			t.Errorf("skew %v: got text %q, want %q", skew, got, want)
+		}
+		if len(page.Columns) != 3 || len(page.Columns[1].Paragraphs) != 2 || len(page.Columns[2].Paragraphs[1].Lines) != 2 {
+			t.Fatalf("skew %v: got columns %+v", skew, page.Columns)
+		}
+		if line := page.Columns[2].Paragraphs[1].Lines[1]; len(line.Results) != 2 || line.Results[0] != 9 || line.Results[1] != 10 {
+			t.Errorf("skew %v: got line %+v, want results [9 10]", skew, line)
+		}
+		if page.Results[0].Text != "Title" || page.Results[6].Text != "c1" {
+			t.Errorf("skew %v: got results not in reading order: %+v", skew, page.Results)
+		}
+	}
+
+	if cols := r.order(nil); cols != nil {
+		t.Errorf("got columns %+v of no results, want none", cols)
+	}
+}
+
+func TestReadingOrderVertical(t *testing.T) {
+	// vertical columns read from right to left, under a horizontal title.
+	results := []Result{
+		{Text: "三", BBox: skewedBox(200, 210, 40, 300, 0), Vertical: true},
+		{Text: "一", BBox: skewedBox(300, 210, 40, 300, 0), Vertical: true},
+		{Text: "Title", BBox: skewedBox(250, 30, 300, 30, 0)},
+		{Text: "二", BBox: skewedBox(250, 210, 40, 300, 0), Vertical: true},
+	}
+	page := &Page{Results: results}
+	page.Columns = (&readingOrder{columnGap: 1, paragraphGap: 0.4}).order(page.Results)
+	if got, want := page.Text(), "Title\n\n一\n二\n三\n"; got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+	if len(page.Columns) != 2 || len(page.Columns[1].Paragraphs) != 1 || len(page.Columns[1].Paragraphs[0].Lines) != 3 {
+		t.Errorf("got columns %+v, want the title and a paragraph of 3 vertical lines", page.Columns)
+	}
+}
+
+func TestPageText(t *testing.T) {
+	page := &Page{Results: []Result{{Text: "hello"}, {Text: "world"}}}
+	if got := page.Text(); got != "hello\nworld\n" {
+		t.Errorf("got text %q without columns", got)
+	}
+
+	page = &Page{
+		Results: []Result{{Text: "hello"}, {Text: "world"}, {Text: "你好"}, {Text: "世界"}, {Text: "ok"}},
+		Columns: []Column{{Paragraphs: []Paragraph{{Lines: []Line{{Results: []int{0, 1}}, {Results: []int{2, 3, 4}}}}}}},
+	}
+	if got := page.Text(); got != "hello world\n你好世界 ok\n" {
+		t.Errorf("got text %q", got)
+	}
+}
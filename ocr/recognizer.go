@@ -1,11 +1,16 @@
 package ocr
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"io/fs"
 	"log"
 	"math"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -14,11 +19,19 @@ import (
 )
 
 type recognizer struct {
-	*Predictor
+	language string
+	pool     *predictorPool
 	batchNum int
-	textLen  int
+	textLen  int  // maximum number of chars of a text line, zero for no limit
+	split    bool // split the text lines longer than textLen instead of truncating them
 	shape    []int
 	labels   []string
+	greedy   bool         // decode the best hypothesis greedily
+	beam     *beamDecoder // nil if neither beam search nor alternatives are needed
+	topK     int          // number of alternative readings
+	chars    bool         // return the characters of the text
+	allowed  string       // allowed chars, empty allows all
+	denied   string       // denied chars
 
 	mean    []float32
 	scale   []float32
@@ -28,38 +41,136 @@ type recognizer struct {
 // newRecognizer creates a new text recognizer.
 func newRecognizer(cfg *Config) (*recognizer, error) {
 	rcfg := cfg.Recognizer
-	model, err := NewPredictor(&cfg.Predictor, rcfg.ModelDir)
+	labels, err := readDict(rcfg.CharDictPath, rcfg.UseSpaceChar)
 	if err != nil {
 		return nil, err
 	}
-	return &recognizer{
-		Predictor: model,
-		batchNum:  rcfg.BatchNum,
-		textLen:   rcfg.MaxTextLength,
-		shape:     rcfg.ImageShape,
-		labels:    readDict(rcfg.CharDictPath),
+	beam, err := newBeamDecoder(&rcfg, labels)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newPredictorPool(&cfg.Predictor, rcfg.ModelDir)
+	if err != nil {
+		return nil, err
+	}
+	p := &recognizer{
+		language: rcfg.Language,
+		pool:     pool,
+		batchNum: rcfg.BatchNum,
+		textLen:  max(rcfg.MaxTextLength, 0),
+		split:    rcfg.SplitLongText,
+		shape:    rcfg.ImageShape,
+		labels:   labels,
+		greedy:   rcfg.Decoder != DecoderBeam,
+		beam:     beam,
+		topK:     max(rcfg.TopK, 0),
+		chars:    rcfg.ReturnChars,
+		allowed:  rcfg.AllowedChars,
+		denied:   rcfg.DeniedChars,
 
 		mean:    []float32{0.5, 0.5, 0.5},
 		scale:   []float32{1 / 0.5, 1 / 0.5, 1 / 0.5},
 		isScale: true,
-	}, nil
+	}
+	if err := p.checkDict(rcfg.CharDictPath); err != nil {
+		pool.close()
+		return nil, err
+	}
+	return p, nil
 }
 
-func readDict(filepath string) []string {
+// readDict reads the labels of the char dict, adding the blank label for ctc
+// and the space label if `useSpace` is true.
+// https://github.com/PaddlePaddle/PaddleOCR/blob/release/2.7/ppocr/postprocess/rec_postprocess.py#L26
+func readDict(filepath string, useSpace bool) ([]string, error) {
 	data, err := os.ReadFile(filepath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, filepath)
+	}
 	if err != nil {
-		log.Println("read recognizer key file error!")
-		return nil
+		return nil, fmt.Errorf("read char dict %s: %w", filepath, err)
 	}
-	labels := strings.Split(string(data), "\n")
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	labels := strings.Split(text, "\n")
 	labels = append([]string{"#"}, labels...) // blank char for ctc
-	labels = append(labels, " ")
-	return labels
+	if useSpace {
+		labels = append(labels, " ")
+	}
+	return labels, nil
 }
 
-func (p *recognizer) run(imgs []gocv.Mat, bboxes [][][]int, dirs []Direction) []Result {
+// checkDict runs the model on a blank image, and checks that the model outputs
+// the probabilities of all labels of the char dict.
+func (p *recognizer) checkDict(filepath string) error {
+	model, err := p.pool.get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer p.pool.put(model)
+
+	h, w := p.shape[1], p.shape[2]
+	_, shape, err := model.Run([]int32{1, 3, int32(h), int32(w)}, make([]float32, 3*h*w))
+	if err != nil {
+		return fmt.Errorf("recognizer warmup: %w", err)
+	}
+	if len(shape) != 3 || int(shape[2]) != len(p.labels) {
+		return fmt.Errorf("%w: %s has %d labels with blank and space, recognizer outputs shape %v",
+			ErrDictMismatch, filepath, len(p.labels), shape)
+	}
+	return nil
+}
+
+// newBeamDecoder creates the beam search decoder configured by `rcfg`,
+// it returns nil for greedy decoding without alternatives.
+func newBeamDecoder(rcfg *RecognizerConfig, labels []string) (*beamDecoder, error) {
+	switch rcfg.Decoder {
+	case "", DecoderGreedy:
+		if rcfg.TopK <= 0 {
+			return nil, nil
+		}
+	case DecoderBeam:
+	default:
+		return nil, fmt.Errorf("ocr: unknown ctc decoder %q", rcfg.Decoder)
+	}
+
+	lm := rcfg.LanguageModel
+	if lm == nil && rcfg.LexiconPath != "" {
+		lexicon, err := ReadLexicon(rcfg.LexiconPath)
+		if err != nil {
+			return nil, err
+		}
+		lm = lexicon
+	}
+	return &beamDecoder{
+		width:    max(rcfg.BeamWidth, rcfg.TopK, 1),
+		labels:   labels,
+		lm:       lm,
+		lmWeight: rcfg.LMWeight,
+	}, nil
+}
+
+// run recognizes the text images, `crops` locates the characters in the source image
+// and may be nil to locate them in the text images.
+func (p *recognizer) run(ctx context.Context, imgs []gocv.Mat, bboxes [][][]int, dirs []Direction, crops []*cropInfo, opts *predictOptions) ([]Result, error) {
+	results, err := p.recognize(ctx, imgs, bboxes, dirs, crops, opts)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(results...), nil
+}
+
+// recognize is like run, but returns the results of each text image,
+// more than one if the text line is split.
+func (p *recognizer) recognize(ctx context.Context, imgs []gocv.Mat, bboxes [][][]int, dirs []Direction, crops []*cropInfo, opts *predictOptions) ([][]Result, error) {
 	t := time.Now()
 	h, w := p.shape[1], p.shape[2]
+	mask := p.charMask(opts)
+
+	model, err := p.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.pool.put(model)
 
 	widths := make([]float64, 0, len(imgs))
 	for _, img := range imgs {
@@ -69,8 +180,11 @@ func (p *recognizer) run(imgs []gocv.Mat, bboxes [][][]int, dirs []Direction) []
 	s := NewFloat64Slice(widths...)
 	sort.Sort(s)
 
-	results := make([]Result, len(imgs))
+	results := make([][]Result, len(imgs))
 	for i := 0; i < len(imgs); i += p.batchNum {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		j := min(i+p.batchNum, len(imgs))
 		batchNum := j - i
 
@@ -81,58 +195,179 @@ func (p *recognizer) run(imgs []gocv.Mat, bboxes [][][]int, dirs []Direction) []
 
 		batchWidth := w
 		normImgs := make([]gocv.Mat, 0, batchNum)
+		resizeWs := make([]int, 0, batchNum)
 		for k := i; k < j; k++ {
-			resizeImg := p.resize(imgs[s.idx[k]], p.shape, maxWhRatio)
+			resizeImg, resizeW := p.resize(imgs[s.idx[k]], p.shape, maxWhRatio)
 			defer resizeImg.Close()
+			resizeWs = append(resizeWs, resizeW)
 
 			normalize(resizeImg, p.mean, p.scale, p.isScale)
 			normImgs = append(normImgs, resizeImg)
 			batchWidth = max(batchWidth, resizeImg.Cols())
 		}
 
-		p.input.Reshape([]int32{int32(batchNum), 3, int32(h), int32(batchWidth)})
-		p.input.CopyFromCpu(permuteBatch(normImgs))
-		p.predictor.Run()
-
-		shape := p.output.Shape()
-		predicts := make([]float32, accumulate(shape))
-		p.output.CopyToCpu(predicts)
+		predicts, shape, err := model.Run([]int32{int32(batchNum), 3, int32(h), int32(batchWidth)}, permuteBatch(normImgs))
+		if err != nil {
+			return nil, err
+		}
 
+		steps, numLabels := int(shape[1]), int(shape[2])
 		for m := 0; m < int(shape[0]); m++ {
-			var (
-				text      string
-				count     int
-				sumScore  float32
-				lastIndex int
-			)
-			for n := 0; n < int(shape[1]); n++ {
-				l := (m*int(shape[1]) + n) * int(shape[2])
-				r := (m*int(shape[1]) + n + 1) * int(shape[2])
-				argmaxIdx, maxValue := argmax(predicts[l:r])
-				if argmaxIdx > 0 && (!(n > 0 && argmaxIdx == lastIndex)) {
-					count += 1
-					sumScore += maxValue
-					text += p.labels[argmaxIdx]
+			idx := s.idx[i+m]
+			probs := predicts[m*steps*numLabels : (m+1)*steps*numLabels]
+			decodeProbs := probs
+			if mask != nil {
+				decodeProbs = maskProbs(probs, steps, numLabels, mask)
+			}
+			hyp, alts := p.decode(decodeProbs, steps, numLabels)
+			if mask != nil {
+				hyp = hyp.rescore(probs, numLabels)
+				for k := range alts {
+					alts[k] = alts[k].rescore(probs, numLabels)
 				}
-				lastIndex = argmaxIdx
 			}
-			var score float32
-			if count > 0 {
-				score = sumScore / float32(count)
+			long := p.textLen > 0 && len(hyp.labels) > p.textLen
+
+			var crop *cropInfo
+			if crops != nil {
+				crop = crops[idx]
+			}
+			var chars []Char
+			if p.chars || long && p.split {
+				// a time step covers batchWidth/steps pixels of the resized image.
+				stepW := float64(batchWidth) / float64(steps) * float64(imgs[idx].Cols()) / float64(resizeWs[m])
+				chars = p.locateChars(hyp, imgs[idx], crop, stepW)
 			}
-			results[s.idx[i+m]] = Result{
-				Text:      text,
-				Direction: dirs[s.idx[i+m]],
-				BBox:      bboxes[s.idx[i+m]],
-				Score:     score,
+			if long && p.split {
+				results[idx] = p.splitText(hyp, chars, dirs[idx], crop)
+				continue
 			}
+			if long {
+				hyp = hyp.truncate(p.textLen)
+			}
+
+			res := Result{
+				Text:      joinLabels(p.labels, hyp.labels),
+				Direction: dirs[idx],
+				BBox:      bboxes[idx],
+				Score:     hyp.confidence(),
+				Vertical:  crop.vertical(),
+				LongText:  long,
+				Language:  p.language,
+			}
+			if crop != nil {
+				res.Polygon = crop.polygon
+			}
+			if p.chars {
+				res.Chars = chars[:len(hyp.labels)]
+			}
+			for _, alt := range alts {
+				if p.textLen > 0 {
+					alt = alt.truncate(p.textLen)
+				}
+				res.Alternatives = append(res.Alternatives, Alternative{
+					Text:     joinLabels(p.labels, alt.labels),
+					Score:    alt.confidence(),
+					LogScore: alt.score,
+				})
+			}
+			results[idx] = []Result{res}
 		}
 	}
 	log.Printf("recognizer: box num: %d, elapsed: %dms\n", len(results), time.Since(t).Milliseconds())
+	return results, nil
+}
+
+// charMask returns whether each label may be recognized, or nil if all labels may be.
+// The chars of `opts` replace the chars of the config, the blank label is always allowed.
+func (p *recognizer) charMask(opts *predictOptions) []bool {
+	allowed, denied := p.allowed, p.denied
+	if opts != nil && opts.allowedChars != "" {
+		allowed = opts.allowedChars
+	}
+	if opts != nil && opts.deniedChars != "" {
+		denied = opts.deniedChars
+	}
+	if allowed == "" && denied == "" {
+		return nil
+	}
+
+	mask := make([]bool, len(p.labels))
+	mask[0] = true
+	for i, label := range p.labels[1:] {
+		mask[i+1] = (allowed == "" || strings.Contains(allowed, label)) && (denied == "" || !strings.Contains(denied, label))
+	}
+	return mask
+}
+
+// decode decodes the CTC output of a single text line,
+// it returns the best hypothesis and the `topK` best hypotheses of beam search.
+func (p *recognizer) decode(probs []float32, steps, numLabels int) (hypothesis, []hypothesis) {
+	var hyps []hypothesis
+	if p.beam != nil {
+		hyps = p.beam.decode(probs, steps, numLabels)
+	}
+	alts := hyps[:min(p.topK, len(hyps))]
+	if p.greedy {
+		return greedyDecode(probs, steps, numLabels), alts
+	}
+	return hyps[0], alts
+}
+
+// splitText splits the text line of `hyp` into results of at most `textLen` chars,
+// each boxed by its `chars` and sharing the polygon of the line.
+// The alternatives are readings of the whole line whose chars do not align with the parts, they are dropped.
+func (p *recognizer) splitText(hyp hypothesis, chars []Char, dir Direction, crop *cropInfo) []Result {
+	var results []Result
+	for i := 0; i < len(hyp.labels); i += p.textLen {
+		j := min(i+p.textLen, len(hyp.labels))
+		part := hypothesis{labels: hyp.labels[i:j], probs: hyp.probs[i:j], spans: hyp.spans[i:j]}
+		res := Result{
+			Text:      joinLabels(p.labels, part.labels),
+			BBox:      [][]int{chars[i].BBox[0], chars[j-1].BBox[1], chars[j-1].BBox[2], chars[i].BBox[3]},
+			Score:     part.confidence(),
+			Direction: dir,
+			Vertical:  crop.vertical(),
+			LongText:  true,
+			Language:  p.language,
+		}
+		if crop != nil {
+			res.Polygon = crop.polygon
+		}
+		if p.chars {
+			res.Chars = chars[i:j]
+		}
+		results = append(results, res)
+	}
 	return results
 }
 
-func (p *recognizer) resize(img gocv.Mat, shape []int, whRatio float64) gocv.Mat {
+// locateChars returns the characters of `hyp`, each spanning the time steps of its label
+// over the full height of the text image `img`.
+// `stepW` is the width of a time step in `img`.
+func (p *recognizer) locateChars(hyp hypothesis, img gocv.Mat, crop *cropInfo, stepW float64) []Char {
+	cols, rows := float64(img.Cols()), float64(img.Rows())
+	chars := make([]Char, len(hyp.labels))
+	for i, label := range hyp.labels {
+		x0 := min(float64(hyp.spans[i][0])*stepW, cols)
+		x1 := min(float64(hyp.spans[i][1]+1)*stepW, cols)
+		chars[i] = Char{
+			Text:  p.labels[label],
+			Score: hyp.probs[i],
+			BBox: [][]int{
+				crop.toImage(x0, 0),
+				crop.toImage(x1, 0),
+				crop.toImage(x1, rows),
+				crop.toImage(x0, rows),
+			},
+		}
+	}
+	return chars
+}
+
+// resize resizes the text image to the height of `shape` keeping its aspect ratio,
+// and pads it to the width of `whRatio`. It also returns the width before padding.
+func (p *recognizer) resize(img gocv.Mat, shape []int, whRatio float64) (gocv.Mat, int) {
 	imgH := shape[1]
 	imgW := int(float64(imgH) * whRatio)
 	ratio := float64(img.Cols()) / float64(img.Rows())
@@ -145,5 +380,5 @@ func (p *recognizer) resize(img gocv.Mat, shape []int, whRatio float64) gocv.Mat
 	resizeImg := gocv.NewMat()
 	gocv.Resize(img, &resizeImg, image.Pt(resizeW, imgH), 0, 0, gocv.InterpolationLinear)
 	gocv.CopyMakeBorder(resizeImg, &resizeImg, 0, 0, 0, imgW-resizeImg.Cols(), gocv.BorderConstant, color.RGBA{0, 0, 0, 0})
-	return resizeImg
+	return resizeImg, resizeW
 }
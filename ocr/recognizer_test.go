@@ -0,0 +1,375 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestRecognizerRun(t *testing.T) {
+	// the wider image is sorted to the second place of the batch.
+	paths := [][]int{{1, 1, 0, 1, 2}, {3, 0, 3, 4, 1}}
+	r, err := newRecognizer(newTestConfig(t, nil, ctcScript(len(testLabels), paths...), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.pool.close()
+
+	if !slices.Equal(r.labels, testLabels) {
+		t.Fatalf("got labels %q, want %q", r.labels, testLabels)
+	}
+
+	imgs := []gocv.Mat{
+		gocv.Zeros(48, 192, gocv.MatTypeCV8UC3),
+		gocv.Zeros(48, 96, gocv.MatTypeCV8UC3),
+	}
+	for _, img := range imgs {
+		defer img.Close()
+	}
+	bboxes := [][][]int{
+		{{0, 0}, {192, 0}, {192, 48}, {0, 48}},
+		{{0, 60}, {96, 60}, {96, 108}, {0, 108}},
+	}
+	dirs := []Direction{{Label: 0, Score: 0.99}, {Label: 1, Score: 0.95}}
+
+	results, err := r.run(context.Background(), imgs, bboxes, dirs, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cc a", "aab"}
+	for i, res := range results {
+		if res.Text != want[i] {
+			t.Errorf("result %d: got text %q, want %q", i, res.Text, want[i])
+		}
+		if math.Abs(float64(res.Score-0.9)) > 1e-6 {
+			t.Errorf("result %d: got score %v, want 0.9", i, res.Score)
+		}
+		if res.Direction != dirs[i] {
+			t.Errorf("result %d: got direction %v, want %v", i, res.Direction, dirs[i])
+		}
+		if !slices.EqualFunc(res.BBox, bboxes[i], slices.Equal) {
+			t.Errorf("result %d: got bbox %v, want %v", i, res.BBox, bboxes[i])
+		}
+		if res.Chars != nil {
+			t.Errorf("result %d: got chars %v, want none", i, res.Chars)
+		}
+	}
+}
+
+func TestRecognizerRunChars(t *testing.T) {
+	cfg := newTestConfig(t, nil, ctcScript(len(testLabels), []int{1, 1, 0, 1, 2}), nil)
+	cfg.Recognizer.ReturnChars = true
+	r, err := newRecognizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.pool.close()
+
+	img := gocv.Zeros(48, 96, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	bboxes := [][][]int{{{0, 60}, {96, 60}, {96, 108}, {0, 108}}}
+	// the text image is cropped 60 pixels below the top of the source image.
+	crop := &cropInfo{pieces: []cropPiece{{m: [3][3]float64{{1, 0, 0}, {0, 1, 60}, {0, 0, 1}}}}, w: 96, h: 48}
+
+	tests := []struct {
+		name  string
+		crops []*cropInfo
+		top   int
+	}{
+		{name: "text image", crops: nil, top: 0},
+		{name: "source image", crops: []*cropInfo{crop}, top: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := r.run(context.Background(), []gocv.Mat{img}, bboxes, make([]Direction, 1), tt.crops, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the batch is padded to 320 pixels, i.e. 40 time steps of 8 pixels.
+			want := []struct {
+				text   string
+				x0, x1 int
+			}{{"a", 0, 16}, {"a", 24, 32}, {"b", 32, 40}}
+			chars := results[0].Chars
+			if len(chars) != len(want) {
+				t.Fatalf("got %d chars, want %d", len(chars), len(want))
+			}
+			for i, w := range want {
+				c := chars[i]
+				if c.Text != w.text || math.Abs(float64(c.Score-0.9)) > 1e-6 {
+					t.Errorf("char %d: got %q (%v), want %q (0.9)", i, c.Text, c.Score, w.text)
+				}
+				box := [][]int{{w.x0, tt.top}, {w.x1, tt.top}, {w.x1, tt.top + 48}, {w.x0, tt.top + 48}}
+				if !slices.EqualFunc(c.BBox, box, slices.Equal) {
+					t.Errorf("char %d: got bbox %v, want %v", i, c.BBox, box)
+				}
+			}
+		})
+	}
+}
+
+func TestRecognizerRunBatches(t *testing.T) {
+	cfg := newTestConfig(t, nil, ctcScript(len(testLabels), []int{2, 3}), nil)
+	cfg.Recognizer.BatchNum = 2
+	r, err := newRecognizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.pool.close()
+
+	imgs := make([]gocv.Mat, 5)
+	bboxes := make([][][]int, len(imgs))
+	for i := range imgs {
+		imgs[i] = gocv.Zeros(32, 32*(i+1), gocv.MatTypeCV8UC3)
+		defer imgs[i].Close()
+	}
+
+	results, err := r.run(context.Background(), imgs, bboxes, make([]Direction, len(imgs)), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(imgs) {
+		t.Fatalf("got %d results, want %d", len(results), len(imgs))
+	}
+	for i, res := range results {
+		if res.Text != "bc" {
+			t.Errorf("result %d: got text %q, want %q", i, res.Text, "bc")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.run(ctx, imgs, bboxes, make([]Direction, len(imgs)), nil, nil); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRecognizerRunTopK(t *testing.T) {
+	// "a" or "b" at the first time step, blanks afterwards.
+	script := func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		n, steps, numLabels := int(shape[0]), int(shape[3])/8, len(testLabels)
+		logits := make([]float32, n*steps*numLabels)
+		for i := 0; i < n; i++ {
+			for t := 0; t < steps; t++ {
+				logits[(i*steps+t)*numLabels] = 1
+			}
+			logits[i*steps*numLabels] = 0
+			logits[i*steps*numLabels+1] = 0.6
+			logits[i*steps*numLabels+2] = 0.4
+		}
+		return [][]float32{logits}, [][]int32{{int32(n), int32(steps), int32(numLabels)}}
+	}
+
+	for _, decoder := range []string{DecoderGreedy, DecoderBeam} {
+		t.Run(decoder, func(t *testing.T) {
+			cfg := newTestConfig(t, nil, script, nil)
+			cfg.Recognizer.Decoder = decoder
+			cfg.Recognizer.BeamWidth = 1
+			cfg.Recognizer.TopK = 2
+			r, err := newRecognizer(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.pool.close()
+
+			img := gocv.Zeros(48, 96, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			results, err := r.run(context.Background(), []gocv.Mat{img}, make([][][]int, 1), make([]Direction, 1), nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Text != "a" {
+				t.Errorf("got text %q, want %q", results[0].Text, "a")
+			}
+			want := []Alternative{{"a", 0.6, math.Log(0.6)}, {"b", 0.4, math.Log(0.4)}}
+			alts := results[0].Alternatives
+			if len(alts) != len(want) {
+				t.Fatalf("got alternatives %v, want %v", alts, want)
+			}
+			for i, w := range want {
+				a := alts[i]
+				if a.Text != w.Text || math.Abs(float64(a.Score-w.Score)) > 1e-6 || math.Abs(a.LogScore-w.LogScore) > 1e-6 {
+					t.Errorf("alternative %d: got %v, want %v", i, a, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRecognizerRunCharMask(t *testing.T) {
+	// "c" is the most probable label at the first time step, then "a" and "b".
+	script := func(shape []int32, _ []float32) ([][]float32, [][]int32) {
+		n, steps, numLabels := int(shape[0]), int(shape[3])/8, len(testLabels)
+		logits := make([]float32, n*steps*numLabels)
+		for i := 0; i < n; i++ {
+			for t := 1; t < steps; t++ {
+				logits[(i*steps+t)*numLabels] = 1
+			}
+			copy(logits[i*steps*numLabels:], []float32{0.02, 0.2, 0.08, 0.7, 0})
+		}
+		return [][]float32{logits}, [][]int32{{int32(n), int32(steps), int32(numLabels)}}
+	}
+
+	tests := []struct {
+		name    string
+		allowed string
+		opts    []PredictOption
+		want    string
+		score   float32
+	}{
+		{name: "no mask", want: "c", score: 0.7},
+		// the scores are the unmasked probabilities, not inflated by renormalizing the allowed labels.
+		{name: "config", allowed: "ab", want: "a", score: 0.2},
+		{name: "denied", allowed: "ab", opts: []PredictOption{WithDeniedChars("a")}, want: "b", score: 0.08},
+		{name: "allowed", allowed: "ab", opts: []PredictOption{WithAllowedChars("c ")}, want: "c", score: 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil, script, nil)
+			cfg.Recognizer.AllowedChars = tt.allowed
+			cfg.Recognizer.ReturnChars = true
+			r, err := newRecognizer(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.pool.close()
+
+			img := gocv.Zeros(48, 96, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			results, err := r.run(context.Background(), []gocv.Mat{img}, make([][][]int, 1), make([]Direction, 1), nil, newPredictOptions(tt.opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Text != tt.want || math.Abs(float64(results[0].Score-tt.score)) > 1e-6 {
+				t.Errorf("got %q (%v), want %q (%v)", results[0].Text, results[0].Score, tt.want, tt.score)
+			}
+			if chars := results[0].Chars; len(chars) != 1 || math.Abs(float64(chars[0].Score-tt.score)) > 1e-6 {
+				t.Errorf("got chars %+v, want a char of score %v", chars, tt.score)
+			}
+		})
+	}
+}
+
+func TestRecognizerDict(t *testing.T) {
+	dict := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(dict, []byte("a\nb\r\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := readDict(dict, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(labels, testLabels) {
+		t.Errorf("got labels %q, want %q", labels, testLabels)
+	}
+
+	// the model does not predict the space label.
+	cfg := newTestConfig(t, nil, ctcScript(len(testLabels)-1, []int{1}), nil)
+	if _, err := newRecognizer(cfg); !errors.Is(err, ErrDictMismatch) {
+		t.Errorf("got error %v, want %v", err, ErrDictMismatch)
+	}
+
+	cfg.Recognizer.UseSpaceChar = false
+	r, err := newRecognizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.pool.close()
+	if want := testLabels[:len(testLabels)-1]; !slices.Equal(r.labels, want) {
+		t.Errorf("got labels %q, want %q", r.labels, want)
+	}
+}
+
+func TestRecognizerRunLongText(t *testing.T) {
+	img := gocv.Zeros(48, 96, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	bbox := [][]int{{0, 0}, {96, 0}, {96, 48}, {0, 48}}
+	// the crop of a polygon mapping the text image to the source image unchanged.
+	poly := [][]int{{0, 0}, {48, 0}, {96, 0}, {96, 48}, {48, 48}, {0, 48}}
+	crop := &cropInfo{pieces: []cropPiece{{m: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}}, w: 96, h: 48, polygon: poly}
+
+	// "abca" with the chars at 0-8, 16-24, 32-40 and 48-56 pixels.
+	tests := []struct {
+		name  string
+		split bool
+		want  []Result
+	}{
+		{
+			name: "truncate",
+			want: []Result{{Text: "ab", BBox: bbox}},
+		},
+		{
+			name:  "split",
+			split: true,
+			want: []Result{
+				{Text: "ab", BBox: [][]int{{0, 0}, {24, 0}, {24, 48}, {0, 48}}},
+				{Text: "ca", BBox: [][]int{{32, 0}, {56, 0}, {56, 48}, {32, 48}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil, ctcScript(len(testLabels), []int{1, 0, 2, 0, 3, 0, 1}), nil)
+			cfg.Recognizer.MaxTextLength = 2
+			cfg.Recognizer.SplitLongText = tt.split
+			r, err := newRecognizer(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.pool.close()
+
+			results, err := r.run(context.Background(), []gocv.Mat{img}, [][][]int{bbox}, make([]Direction, 1), []*cropInfo{crop}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+			for i, want := range tt.want {
+				res := results[i]
+				if res.Text != want.Text || !res.LongText || res.Chars != nil {
+					t.Errorf("result %d: got %+v, want text %q flagged as long text", i, res, want.Text)
+				}
+				if !slices.EqualFunc(res.BBox, want.BBox, slices.Equal) {
+					t.Errorf("result %d: got bbox %v, want %v", i, res.BBox, want.BBox)
+				}
+				// the parts of a split line keep the polygon of the line.
+				if !slices.EqualFunc(res.Polygon, poly, slices.Equal) {
+					t.Errorf("result %d: got polygon %v, want %v", i, res.Polygon, poly)
+				}
+			}
+		})
+	}
+}
+
+func TestRecognizerRunDefaultLength(t *testing.T) {
+	// a line of 30 chars "abcabc..." is not cut by the default config.
+	path := make([]int, 30)
+	for i := range path {
+		path[i] = 1 + i%3
+	}
+	cfg := newTestConfig(t, nil, ctcScript(len(testLabels), path), nil)
+	r, err := newRecognizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.pool.close()
+
+	img := gocv.Zeros(48, 480, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	bbox := [][]int{{0, 0}, {480, 0}, {480, 48}, {0, 48}}
+	results, err := r.run(context.Background(), []gocv.Mat{img}, [][][]int{bbox}, make([]Direction, 1), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("abc", 10); len(results) != 1 || results[0].Text != want || results[0].LongText {
+		t.Errorf("got results %+v, want the whole text %q", results, want)
+	}
+}
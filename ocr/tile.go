@@ -0,0 +1,98 @@
+package ocr
+
+import (
+	"context"
+	"image"
+
+	clipper "github.com/ctessum/go.clipper"
+	"gocv.io/x/gocv"
+)
+
+// tileMergeThresh is the minimum intersection of two boxes of overlapping tiles to merge them,
+// relative to the smaller of the boxes clipped to the overlap of the tiles.
+const tileMergeThresh = 0.5
+
+// tileBox is a box detected in the tile `tile` of the image.
+type tileBox struct {
+	box  [][]int
+	tile image.Rectangle
+}
+
+// runTiles detects the text in overlapping tiles of the image at native resolution,
+// then merges the boxes split or duplicated across the tile seams.
+func (d *detector) runTiles(ctx context.Context, model *Predictor, img gocv.Mat, maps *DetectionMaps) ([][][]int, error) {
+	h, w := img.Rows(), img.Cols()
+	var boxes []tileBox
+	for _, y := range tileStarts(h, d.tileSize, d.tileOverlap) {
+		for _, x := range tileStarts(w, d.tileSize, d.tileOverlap) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			rect := image.Rect(x, y, min(x+d.tileSize, w), min(y+d.tileSize, h))
+			tile := img.Region(rect)
+			var tileMaps *DetectionMaps
+			if maps != nil {
+				tileMaps = maps.region(rect)
+			}
+			tileBoxes, err := d.predict(model, tile, 1, tileMaps)
+			tile.Close()
+			if tileMaps != nil {
+				tileMaps.Close()
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, box := range tileBoxes {
+				for _, pt := range box {
+					pt[0] += x
+					pt[1] += y
+				}
+				boxes = append(boxes, tileBox{box: box, tile: rect})
+			}
+		}
+	}
+	return d.mergeTileBoxes(boxes, w, h), nil
+}
+
+// tileStarts returns the offsets of the tiles of `size` overlapping by `overlap` along a side of length `n`,
+// the last tile ends at the end of the side. The overlap is at most half of the tile.
+func tileStarts(n, size, overlap int) []int {
+	if n <= size {
+		return []int{0}
+	}
+	step := max(size-overlap, size/2, 1)
+	var starts []int
+	for x := 0; x+size < n; x += step {
+		starts = append(starts, x)
+	}
+	return append(starts, n-size)
+}
+
+// mergeTileBoxes merges the boxes of overlapping tiles which cover the same text inside the overlap.
+func (d *detector) mergeTileBoxes(boxes []tileBox, w, h int) [][][]int {
+	polys := make([][][]int, len(boxes))
+	for i, b := range boxes {
+		polys[i] = b.box
+	}
+	return d.mergeBoxes(polys, w, h, func(i, j int, a, b clipper.Path) bool {
+		seam := boxes[i].tile.Intersect(boxes[j].tile)
+		if boxes[i].tile == boxes[j].tile || seam.Empty() {
+			return false
+		}
+		return seamOverlap(a, b, seam) >= tileMergeThresh
+	})
+}
+
+// seamOverlap returns the intersection area of the boxes relative to the smaller of them clipped to `seam`.
+func seamOverlap(a, b clipper.Path, seam image.Rectangle) float64 {
+	inter := pathsArea(clipPaths(clipper.CtIntersection, clipper.Paths{a}, clipper.Paths{b}))
+	if inter == 0 {
+		return 0
+	}
+	seamPath := toPath([][]int{
+		{seam.Min.X, seam.Min.Y}, {seam.Max.X, seam.Min.Y}, {seam.Max.X, seam.Max.Y}, {seam.Min.X, seam.Max.Y},
+	})
+	areaA := pathsArea(clipPaths(clipper.CtIntersection, clipper.Paths{a}, clipper.Paths{seamPath}))
+	areaB := pathsArea(clipPaths(clipper.CtIntersection, clipper.Paths{b}, clipper.Paths{seamPath}))
+	return inter / max(min(areaA, areaB), 1)
+}
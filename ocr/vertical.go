@@ -0,0 +1,131 @@
+package ocr
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Recognition modes of vertical text, RecognizerConfig.VerticalText.
+const (
+	VerticalRotate = "rotate"
+	VerticalStack  = "stack"
+)
+
+// cropStack is the text image of a vertical column whose characters are stacked upright from left to right.
+type cropStack struct {
+	cells []stackCell
+	w, h  int // size of the text image
+	colW  int // width of the column
+}
+
+// stackCell is a character of the column placed at (`x`, `y`) of the text image.
+type stackCell struct {
+	x, y   int
+	y0, y1 int // rows of the character in the column
+}
+
+// toColumn maps the point (x, y) of the text image to the column.
+func (s *cropStack) toColumn(x, y float64) (float64, float64) {
+	cell := s.cells[0]
+	for _, c := range s.cells[1:] {
+		if x >= float64(c.x) {
+			cell = c
+		}
+	}
+	return clamp(x-float64(cell.x), 0, float64(s.colW)),
+		float64(cell.y0) + clamp(y-float64(cell.y), 0, float64(cell.y1-cell.y0))
+}
+
+// stackColumn returns the text image of the vertical column cropped by getRotateCropImage or getPolyCropImage,
+// with its characters cut by the projection profile and stacked upright from left to right,
+// so that they are read by models of horizontal text. It updates `crop` to map the text image to the column.
+func stackColumn(img gocv.Mat, crop *cropInfo) gocv.Mat {
+	column := gocv.NewMat()
+	defer column.Close()
+	gocv.Rotate(img, &column, gocv.Rotate90Clockwise)
+	w := column.Cols()
+
+	// the gap between the characters keeps the repeated ones apart for CTC decoding.
+	segs := segmentColumn(column)
+	gap := max(w/4, 1)
+	stack := &cropStack{w: len(segs)*(w+gap) - gap, colW: w}
+	for _, seg := range segs {
+		stack.h = max(stack.h, seg[1]-seg[0])
+	}
+
+	dstImg := gocv.NewMatWithSizeFromScalar(column.Mean(), stack.h, stack.w, column.Type())
+	x := 0
+	for _, seg := range segs {
+		y := (stack.h - (seg[1] - seg[0])) / 2
+		src := column.Region(image.Rect(0, seg[0], w, seg[1]))
+		dst := dstImg.Region(image.Rect(x, y, x+w, y+seg[1]-seg[0]))
+		src.CopyTo(&dst)
+		dst.Close()
+		src.Close()
+		stack.cells = append(stack.cells, stackCell{x: x, y: y, y0: seg[0], y1: seg[1]})
+		x += w + gap
+	}
+	crop.rotated = false
+	crop.stack = stack
+	return dstImg
+}
+
+// segmentColumn returns the rows of the characters of the column from top to bottom.
+// The characters are the runs of rows containing ink, merged if they are parts of a character
+// and split if they are touching characters, assuming the characters are about square.
+func segmentColumn(column gocv.Mat) [][2]int {
+	w, h := column.Cols(), column.Rows()
+	gray := gocv.NewMat()
+	defer gray.Close()
+	if column.Channels() == 3 {
+		gocv.CvtColor(column, &gray, gocv.ColorBGRToGray)
+	} else {
+		column.CopyTo(&gray)
+	}
+	ink := gocv.NewMat()
+	defer ink.Close()
+	gocv.Threshold(gray, &ink, 0, 255, gocv.ThresholdBinary|gocv.ThresholdOtsu)
+	if gocv.CountNonZero(ink) > w*h/2 {
+		gocv.BitwiseNot(ink, &ink)
+	}
+
+	var runs [][2]int
+	start, minInk := -1, max(w/20, 1)
+	for y := 0; y <= h; y++ {
+		count := 0
+		for x := 0; y < h && x < w; x++ {
+			if ink.GetUCharAt(y, x) > 0 {
+				count++
+			}
+		}
+		if count >= minInk && start < 0 {
+			start = y
+		} else if count < minInk && start >= 0 {
+			runs = append(runs, [2]int{start, y})
+			start = -1
+		}
+	}
+
+	var segs [][2]int
+	for _, run := range runs {
+		if n := len(segs); n > 0 && run[1]-segs[n-1][0] <= w*6/5 {
+			segs[n-1][1] = run[1]
+			continue
+		}
+		segs = append(segs, run)
+	}
+	if len(segs) == 0 {
+		segs = [][2]int{{0, h}}
+	}
+
+	var chars [][2]int
+	for _, seg := range segs {
+		n := max(int(math.Round(float64(seg[1]-seg[0])/float64(max(w, 1)))), 1)
+		for k := 0; k < n; k++ {
+			chars = append(chars, [2]int{seg[0] + (seg[1]-seg[0])*k/n, seg[0] + (seg[1]-seg[0])*(k+1)/n})
+		}
+	}
+	return chars
+}
@@ -0,0 +1,94 @@
+package ocr
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// newTestColumn returns a 32 x 200 column with a char, a char of two parts and two touching chars.
+func newTestColumn() gocv.Mat {
+	column := gocv.Zeros(200, 32, gocv.MatTypeCV8UC3)
+	for _, r := range []image.Rectangle{image.Rect(6, 4, 26, 30), image.Rect(6, 40, 26, 48), image.Rect(6, 56, 26, 66), image.Rect(6, 76, 26, 140)} {
+		gocv.Rectangle(&column, r, color.RGBA{255, 255, 255, 0}, -1)
+	}
+	return column
+}
+
+func TestSegmentColumn(t *testing.T) {
+	column := newTestColumn()
+	defer column.Close()
+
+	want := [][2]int{{4, 30}, {40, 66}, {76, 108}, {108, 140}}
+	if got := segmentColumn(column); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	blank := gocv.Zeros(100, 32, gocv.MatTypeCV8UC3)
+	defer blank.Close()
+	if got, want := segmentColumn(blank), [][2]int{{0, 33}, {33, 66}, {66, 100}}; !slices.Equal(got, want) {
+		t.Errorf("got %v for a blank column, want %v", got, want)
+	}
+}
+
+func TestStackColumn(t *testing.T) {
+	column := newTestColumn()
+	defer column.Close()
+	// the column cropped by getRotateCropImage.
+	img := gocv.NewMat()
+	defer img.Close()
+	gocv.Rotate(column, &img, gocv.Rotate90CounterClockwise)
+	crop := &cropInfo{pieces: []cropPiece{{m: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}}, w: 32, h: 200, rotated: true}
+
+	stacked := stackColumn(img, crop)
+	defer stacked.Close()
+	if w, h := stacked.Cols(), stacked.Rows(); w != 4*32+3*8 || h != 32 {
+		t.Fatalf("got text image of %dx%d, want %dx%d", w, h, 4*32+3*8, 32)
+	}
+	if w, h := crop.size(); w != stacked.Cols() || h != stacked.Rows() {
+		t.Errorf("got crop size %dx%d, want the size of the text image", w, h)
+	}
+	if !crop.vertical() {
+		t.Error("got a horizontal crop, want a vertical one")
+	}
+	// the first char is centered in its cell, upright.
+	if v := stacked.GetVecbAt(16, 16)[0]; v != 255 {
+		t.Errorf("got %d at the first char, want 255", v)
+	}
+	if v := stacked.GetVecbAt(16, 36)[0]; v == 255 {
+		t.Errorf("got %d between the chars, want the background", v)
+	}
+	// the second char is at x 40 and y 3 of the text image, rows 40 to 66 of the column.
+	if got, want := crop.toImage(50, 8), []int{10, 45}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPredictVerticalText(t *testing.T) {
+	text := image.Rect(40, 8, 72, 120)
+	for _, boxType := range []string{BoxTypeQuad, BoxTypePoly} {
+		for _, mode := range []string{VerticalRotate, VerticalStack} {
+			cfg := newTestConfig(t, probMapScript(text), ctcScript(len(testLabels), []int{1, 0, 2}), nil)
+			cfg.Detector.BoxType = boxType
+			cfg.Recognizer.VerticalText = mode
+			o, err := NewWithConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+
+			img := gocv.Zeros(128, 128, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			results, err := o.Predict(img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Text != "ab" || !results[0].Vertical {
+				t.Errorf("%s %s: got %+v, want a single vertical result of text %q", boxType, mode, results, "ab")
+			}
+		}
+	}
+}